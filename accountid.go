@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const stsURL = "https://sts.amazonaws.com"
+
+type AssumeRoleResponse struct {
+	XMLName     xml.Name `xml:"AssumeRoleResponse"`
+	Credentials struct {
+		AccessKeyId     string `xml:"AccessKeyId"`
+		SecretAccessKey string `xml:"SecretAccessKey"`
+		SessionToken    string `xml:"SessionToken"`
+	} `xml:"AssumeRoleResult>Credentials"`
+}
+
+// assumeRole gets temporary credentials for roleARN, narrowed by a session policy
+func assumeRole(ctx context.Context, config *Config, roleARN, policy string) (*awsCredentials, error) {
+	query := url.Values{
+		"Action":          {"AssumeRole"},
+		"Version":         {"2011-06-15"},
+		"RoleArn":         {roleARN},
+		"RoleSessionName": {"bucket-finder"},
+		"DurationSeconds": {"900"},
+		"Policy":          {policy},
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", stsURL+"/?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	signRequest(req, config.credentials, "us-east-1", "sts", time.Now())
+
+	resp, body, err := doRequest(config, req)
+	if err != nil {
+		return nil, err
+	}
+	var result AssumeRoleResponse
+	if resp.StatusCode != http.StatusOK || xml.Unmarshal(body, &result) != nil || result.Credentials.AccessKeyId == "" {
+		return nil, fmt.Errorf("AssumeRole returned %s: %s", resp.Status, evidenceSnippet(string(body)))
+	}
+	return &awsCredentials{
+		accessKey:    result.Credentials.AccessKeyId,
+		secretKey:    result.Credentials.SecretAccessKey,
+		sessionToken: result.Credentials.SessionToken,
+	}, nil
+}
+
+// ownerPolicy allows listing bucketName only if its owning account ID
+// starts with prefix
+func ownerPolicy(bucketName, prefix string) string {
+	return fmt.Sprintf(`{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["s3:ListBucket","s3:GetObject"],`+
+		`"Resource":["arn:aws:s3:::%s","arn:aws:s3:::%s/*"],"Condition":{"StringLike":{"s3:ResourceAccount":["%s*"]}}}]}`,
+		bucketName, bucketName, prefix)
+}
+
+// findBucketOwner works out the account owning a bucket the role can list,
+// a digit at a time: a session policy only allows the listing if the
+// s3:ResourceAccount condition matches the account ID guessed so far. The
+// result is confirmed with x-amz-expected-bucket-owner.
+func findBucketOwner(ctx context.Context, config *Config, bucketName, host string) (string, error) {
+	region := regionForHost(host, config.dualstack)
+	list := func(creds *awsCredentials, expectedOwner string) (bool, error) {
+		req, err := http.NewRequestWithContext(ctx, "GET", bucketURL(host, bucketName)+"?max-keys=1", nil)
+		if err != nil {
+			return false, err
+		}
+		if expectedOwner != "" {
+			req.Header.Set("x-amz-expected-bucket-owner", expectedOwner)
+		}
+		signRequest(req, creds, region, "s3", time.Now())
+		resp, _, err := doRequest(config, req)
+		if err != nil {
+			return false, err
+		}
+		return resp.StatusCode == http.StatusOK, nil
+	}
+
+	account := ""
+	for len(account) < 12 {
+		found := false
+		for digit := '0'; digit <= '9' && !found; digit++ {
+			creds, err := assumeRole(ctx, config, config.ownerRole, ownerPolicy(bucketName, account+string(digit)))
+			if err != nil {
+				return "", err
+			}
+			if found, err = list(creds, ""); err != nil {
+				return "", err
+			}
+			if found {
+				account += string(digit)
+			}
+		}
+		if !found {
+			return "", fmt.Errorf("the role cannot list %s (found %q so far)", bucketName, account)
+		}
+	}
+
+	if ok, err := list(config.credentials, account); err != nil || !ok {
+		return "", fmt.Errorf("x-amz-expected-bucket-owner did not confirm account %s", account)
+	}
+	return account, nil
+}
+
+// checkBucketOwner reports the account that owns a listable bucket
+func checkBucketOwner(ctx context.Context, config *Config, bucketName, host string, depth, workerId int) {
+	tabs := strings.Repeat("\t", depth+1)
+	workerPrefix := ""
+	if config.verbose {
+		workerPrefix = fmt.Sprintf("[Worker %d] ", workerId)
+	}
+
+	account, err := findBucketOwner(ctx, config, bucketName, host)
+	if err != nil {
+		if config.verbose {
+			fmt.Printf("%s%sCould not find the owner of %s: %v\n", workerPrefix, tabs, bucketName, err)
+		}
+		return
+	}
+
+	msg := fmt.Sprintf("%s%sBucket %s is owned by AWS account %s", workerPrefix, tabs, bucketName, account)
+	fmt.Println(msg)
+	if config.logger != nil {
+		config.logger.Println(msg)
+	}
+	recordFinding(ctx, config, Finding{
+		Bucket:    bucketName,
+		URL:       bucketURL(host, bucketName),
+		Type:      findingBucketOwner,
+		Severity:  "info",
+		Message:   fmt.Sprintf("Bucket %s belongs to AWS account %s", bucketName, account),
+		AccountID: account,
+	})
+}
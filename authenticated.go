@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// regionForHost is the AWS region served by an S3 endpoint, for signing
+func regionForHost(host string, dualstack bool) string {
+	for region := range regionEndpoints {
+		if getHostForRegion(region, dualstack) == host {
+			return region
+		}
+	}
+	for _, region := range awsRegions {
+		if getHostForRegion(region, dualstack) == host {
+			return region
+		}
+	}
+	if region := regionFromEndpoint(strings.TrimPrefix(strings.TrimPrefix(host, "https://"), "http://")); region != "" {
+		return region
+	}
+	return "us-east-1"
+}
+
+// checkAuthenticated lists a bucket that refuses anonymous listing again,
+// this time signed, to catch buckets open to "Any authenticated AWS user"
+func checkAuthenticated(ctx context.Context, config *Config, bucketName, host string, depth, workerId int) {
+	tabs := strings.Repeat("\t", depth+1)
+	workerPrefix := ""
+	if config.verbose {
+		workerPrefix = fmt.Sprintf("[Worker %d] ", workerId)
+	}
+
+	listURL := bucketURL(host, bucketName)
+	req, err := http.NewRequestWithContext(ctx, "GET", listURL, nil)
+	if err != nil {
+		return
+	}
+	signRequest(req, config.credentials, regionForHost(host, config.dualstack), "s3", time.Now())
+	resp, body, err := doRequest(config, req)
+	if err != nil {
+		return
+	}
+
+	var listResult ListBucketResult
+	if resp.StatusCode != http.StatusOK || xml.Unmarshal(body, &listResult) != nil || listResult.Name == "" {
+		if config.verbose {
+			fmt.Printf("%s%sAuthenticated listing of %s refused (%s)\n", workerPrefix, tabs, bucketName, resp.Status)
+		}
+		return
+	}
+	if config.evidence != nil {
+		config.evidence.recordExchange(listURL, resp, body)
+	}
+
+	msg := fmt.Sprintf("%s%sBucket listable by any authenticated AWS user: %s ( %s, %d objects on the first page )",
+		workerPrefix, tabs, bucketName, listURL, len(listResult.Contents))
+	fmt.Println(msg)
+	if config.logger != nil {
+		config.logger.Println(msg)
+	}
+	recordFinding(ctx, config, Finding{
+		Bucket:   bucketName,
+		URL:      listURL,
+		Type:     findingBucketAuthListable,
+		Severity: "high",
+		Message:  fmt.Sprintf("Bucket %s denies anonymous listing but can be listed by any authenticated AWS user", bucketName),
+		Evidence: evidenceSnippet(string(body)),
+	})
+
+	if config.ownerRole != "" {
+		checkBucketOwner(ctx, config, bucketName, host, depth, workerId)
+	}
+}
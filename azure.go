@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Containers tried in each storage account unless --azure-containers says otherwise
+var azureDefaultContainers = []string{
+	"public", "data", "files", "backup", "backups", "images", "uploads", "media",
+	"assets", "static", "content", "logs", "docs", "downloads", "$web",
+}
+
+var (
+	azureAccountName   = regexp.MustCompile(`^[a-z0-9]{3,24}$`)
+	azureContainerName = regexp.MustCompile(`^(?:[a-z0-9](?:[a-z0-9]|-[a-z0-9]){2,62}|\$web|\$root)$`)
+)
+
+// azureBlobList is the XML body of List Blobs
+type azureBlobList struct {
+	XMLName xml.Name `xml:"EnumerationResults"`
+	Blobs   []struct {
+		Name       string `xml:"Name"`
+		Properties struct {
+			LastModified  string `xml:"Last-Modified"`
+			ETag          string `xml:"Etag"`
+			ContentLength int64  `xml:"Content-Length"`
+		} `xml:"Properties"`
+	} `xml:"Blobs>Blob"`
+	NextMarker string `xml:"NextMarker"`
+}
+
+// azureProvider probes Azure Blob Storage containers at
+// <account>.blob.core.windows.net/<container>. A candidate is either a storage
+// account, whose containers are guessed, or one container given as
+// account/container. Containers are reported by host and name
+// (acme.blob.core.windows.net/backups), as container names are only unique
+// within their account. Azure
+// answers the same way for missing and private containers, and only
+// containers with public container access can be listed, so only those are
+// reported.
+type azureProvider struct {
+	containers []string
+}
+
+func newAzureProvider(config *Config) (Provider, error) {
+	containers := azureDefaultContainers
+	if config.azureContainers != "" {
+		containers = nil
+		for _, c := range strings.Split(config.azureContainers, ",") {
+			if c = strings.ToLower(strings.TrimSpace(c)); c == "" {
+				continue
+			}
+			if !azureContainerName.MatchString(c) {
+				return nil, fmt.Errorf("%q is not a valid Azure container name", c)
+			}
+			containers = append(containers, c)
+		}
+	}
+	return &azureProvider{containers: containers}, nil
+}
+
+func (p *azureProvider) Name() string {
+	return "azure"
+}
+
+// targets maps a candidate onto the account and containers to try
+func (p *azureProvider) targets(candidate string) (string, []string, bool) {
+	candidate = strings.ToLower(strings.Trim(candidate, "/"))
+	account, container, explicit := strings.Cut(candidate, "/")
+	account = strings.TrimSuffix(account, ".blob.core.windows.net")
+	if !azureAccountName.MatchString(account) {
+		return "", nil, false
+	}
+	if !explicit {
+		return account, p.containers, true
+	}
+	if !azureContainerName.MatchString(container) {
+		return "", nil, false
+	}
+	return account, []string{container}, true
+}
+
+func (p *azureProvider) Probe(ctx context.Context, config *Config, bucketName string, workerId int) error {
+	account, containers, ok := p.targets(bucketName)
+	if !ok {
+		if config.verbose {
+			fmt.Printf("[Worker %d] Skipping %s: not a valid Azure storage account name\n", workerId, bucketName)
+		}
+		return nil
+	}
+
+	for _, container := range containers {
+		if err := p.probeContainer(ctx, config, account, container, workerId); err != nil {
+			// An account that doesn't exist has no DNS name
+			var dnsErr *net.DNSError
+			if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+				if config.verbose {
+					fmt.Printf("[Worker %d] No Azure storage account %s\n", workerId, account)
+				}
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *azureProvider) probeContainer(ctx context.Context, config *Config, account, container string, workerId int) error {
+	// The container is named by its host and path, so its URL is https://<name>
+	// and object URLs are https://<name>/<blob>
+	name := fmt.Sprintf("%s.blob.core.windows.net/%s", account, container)
+	host := "https://" + bucketPlaceholder
+
+	listURL := bucketURL(host, name) + "?restype=container&comp=list"
+	resp, body, err := fetchURL(ctx, config, "GET", listURL)
+	if err != nil {
+		return err
+	}
+	if config.evidence != nil {
+		defer config.evidence.forget(listURL)
+	}
+	// Azure asks callers to slow down with 503 ServerBusy
+	if isSlowDown(resp, body) || resp.StatusCode == http.StatusServiceUnavailable {
+		return errSlowDown
+	}
+	if resp.StatusCode != http.StatusOK {
+		if config.verbose {
+			fmt.Printf("[Worker %d] %s: %s\n", workerId, name, resp.Status)
+		}
+		return nil
+	}
+
+	listResult, err := azureListing(config, host, name, body)
+	if err != nil {
+		return nil
+	}
+	processListing(ctx, config, listResult, string(body), name, host, 0, workerId)
+	return nil
+}
+
+// azureListing converts a page of List Blobs into a bucket listing that
+// continues from NextMarker
+func azureListing(config *Config, host, name string, body []byte) (ListBucketResult, error) {
+	var list azureBlobList
+	if err := xml.Unmarshal(body, &list); err != nil {
+		return ListBucketResult{}, err
+	}
+
+	listResult := ListBucketResult{Name: name, IsTruncated: list.NextMarker != ""}
+	for _, blob := range list.Blobs {
+		listResult.Contents = append(listResult.Contents, ListBucketObject{
+			Key:          blob.Name,
+			LastModified: blob.Properties.LastModified,
+			ETag:         blob.Properties.ETag,
+			Size:         blob.Properties.ContentLength,
+		})
+	}
+	if listResult.IsTruncated {
+		listResult.nextPage = func(ctx context.Context) (ListBucketResult, error) {
+			pageURL := bucketURL(host, name) + "?restype=container&comp=list&marker=" + url.QueryEscape(list.NextMarker)
+			resp, body, err := fetchURL(ctx, config, "GET", pageURL)
+			if config.evidence != nil {
+				config.evidence.forget(pageURL)
+			}
+			if err != nil {
+				return ListBucketResult{}, err
+			}
+			if resp.StatusCode != http.StatusOK {
+				return ListBucketResult{}, fmt.Errorf("listing page returned %s", resp.Status)
+			}
+			return azureListing(config, host, name, body)
+		}
+	}
+	return listResult, nil
+}
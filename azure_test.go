@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// nxdomain answers a DNS query with NXDOMAIN
+func nxdomain(query []byte) []byte {
+	if len(query) < 12 {
+		return nil
+	}
+	answer := append([]byte(nil), query...)
+	binary.BigEndian.PutUint16(answer[2:], 0x8183) // response, RD, RA, NXDOMAIN
+	binary.BigEndian.PutUint16(answer[6:], 0)      // no answers
+	binary.BigEndian.PutUint16(answer[8:], 0)
+	binary.BigEndian.PutUint16(answer[10:], 0)
+	return answer
+}
+
+// A storage account that doesn't exist must be told apart from a failed
+// probe whichever resolver looks it up
+func TestAzureMissingAccountThroughResolvers(t *testing.T) {
+	var queries atomic.Int64
+
+	// --resolver: a DNS server of its own
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			queries.Add(1)
+			pc.WriteTo(nxdomain(buf[:n]), addr)
+		}
+	}()
+
+	// --doh: a DNS-over-HTTPS endpoint
+	doh := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query, _ := io.ReadAll(r.Body)
+		queries.Add(1)
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(nxdomain(query))
+	}))
+	defer doh.Close()
+
+	// The DoH client is cloned from the default transport, which has to
+	// trust the test server's certificate
+	defaultTransport := http.DefaultTransport.(*http.Transport)
+	savedTLS := defaultTransport.TLSClientConfig
+	defaultTransport.TLSClientConfig = &tls.Config{RootCAs: doh.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs}
+	dohResolver, err := newDoHResolver(doh.URL, nil)
+	defaultTransport.TLSClientConfig = savedTLS
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resolvers := map[string]*net.Resolver{
+		"resolver": newResolver(pc.LocalAddr().String()),
+		"doh":      dohResolver,
+	}
+	for name, resolver := range resolvers {
+		t.Run(name, func(t *testing.T) {
+			before := queries.Load()
+			config := &Config{dns: newDNSCache(resolver), metrics: &scanMetrics{}}
+			config.client = &http.Client{Transport: newTransport(config)}
+
+			p := &azureProvider{containers: []string{"public"}}
+			if err := p.Probe(context.Background(), config, "nosuchacct", 0); err != nil {
+				t.Errorf("missing account reported as an error: %v", err)
+			}
+			if queries.Load() == before {
+				t.Error("the account was not looked up through the resolver")
+			}
+		})
+	}
+}
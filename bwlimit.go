@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// bandwidthLimiter caps the combined rate of all downloads, for --bw-limit
+type bandwidthLimiter struct {
+	rate float64 // bytes per second
+
+	mu   sync.Mutex
+	next time.Time // when the bytes granted so far have been paid for
+}
+
+// newBandwidthLimiter parses a rate such as 10MB/s or 500KB
+func newBandwidthLimiter(limit string) (*bandwidthLimiter, error) {
+	rate, err := parseByteSize(strings.TrimSuffix(strings.TrimSpace(limit), "/s"))
+	if err != nil {
+		return nil, err
+	}
+	if rate <= 0 {
+		return nil, fmt.Errorf("rate must be above zero")
+	}
+	return &bandwidthLimiter{rate: float64(rate)}, nil
+}
+
+// wait blocks until n more bytes may be transferred
+func (l *bandwidthLimiter) wait(n int) {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	l.next = l.next.Add(time.Duration(float64(n) / l.rate * float64(time.Second)))
+	delay := l.next.Sub(now)
+	l.mu.Unlock()
+
+	time.Sleep(delay)
+}
+
+// Reads are kept small so the downloads sharing the limit take turns
+const throttleChunk = 16 << 10
+
+type throttledReader struct {
+	r       io.Reader
+	limiter *bandwidthLimiter
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	if len(p) > throttleChunk {
+		p = p[:throttleChunk]
+	}
+	n, err := t.r.Read(p)
+	if n > 0 {
+		t.limiter.wait(n)
+	}
+	return n, err
+}
+
+// throttle limits r to the --bw-limit rate, if one is set
+func throttle(config *Config, r io.Reader) io.Reader {
+	if config.bandwidth == nil {
+		return r
+	}
+	return &throttledReader{r: r, limiter: config.bandwidth}
+}
@@ -0,0 +1,26 @@
+package main
+
+import "context"
+
+// checkFoundBucket runs the opt-in checks on an S3-style bucket that
+// exists, whether or not it can be listed
+func checkFoundBucket(ctx context.Context, config *Config, bucketName, host string, depth, workerId int) {
+	if config.tagging {
+		checkTagging(ctx, config, bucketName, host, depth, workerId)
+	}
+	if config.lifecycle {
+		checkLifecycle(ctx, config, bucketName, host, depth, workerId)
+	}
+	if config.uploads {
+		checkUploads(ctx, config, bucketName, host, depth, workerId)
+	}
+	if config.versions {
+		checkVersioning(ctx, config, bucketName, host, depth, workerId)
+	}
+	if config.checkMultipart {
+		checkMultipart(ctx, config, bucketName, host, depth, workerId)
+	}
+	if config.checkWrite {
+		checkWrite(ctx, config, bucketName, host, depth, workerId)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// crt.sh searches the public Certificate Transparency logs
+const crtShURL = "https://crt.sh/"
+
+// Busy domains have tens of thousands of certificates; enough is enough
+const maxCTHosts = 500
+
+type crtShEntry struct {
+	NameValue string `json:"name_value"`
+}
+
+// ctSubdomains returns the host names under domain that appear in
+// certificates logged to Certificate Transparency
+func ctSubdomains(config *Config, domain string) ([]string, error) {
+	query := crtShURL + "?q=" + url.QueryEscape("%."+domain) + "&output=json"
+
+	// crt.sh is slow for large domains
+	client := &http.Client{Timeout: 2 * time.Minute, Transport: config.transport}
+	resp, err := client.Get(query)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("crt.sh returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var entries []crtShEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("parsing crt.sh response: %v", err)
+	}
+
+	seen := make(map[string]bool)
+	for _, entry := range entries {
+		// One certificate can hold many names, one per line
+		for _, host := range strings.Split(entry.NameValue, "\n") {
+			host = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(host), "*."))
+			if host != domain && sameSite(host, domain) {
+				seen[host] = true
+			}
+		}
+	}
+
+	var hosts []string
+	for host := range seen {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	if len(hosts) > maxCTHosts {
+		hosts = hosts[:maxCTHosts]
+	}
+	return hosts, nil
+}
+
+// seedFromCT turns a domain's CT subdomains into candidates: each host name
+// itself (website buckets are named after the host) and its labels joined
+// with the domain name, without running every host through the full
+// permutation set
+func seedFromCT(config *Config, domain string) (*seedResult, error) {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	hosts, err := ctSubdomains(config, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	perms := make(map[string]bool)
+	for _, host := range hosts {
+		addPermutation(perms, host)
+		generateDomainPermutations(perms, host)
+	}
+
+	result := &seedResult{keywords: []string{domain}}
+	for name := range perms {
+		if config.rules.validName(name) {
+			result.names = append(result.names, name)
+		}
+	}
+	sort.Strings(result.names)
+
+	if config.verbose {
+		fmt.Printf("Found %d subdomains of %s in CT logs\n", len(hosts), domain)
+	}
+	return result, nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// etagDedup tracks the content downloaded so far by ETag and size, so the
+// same content found under other keys or in other buckets isn't fetched again
+type etagDedup struct {
+	mu      sync.Mutex
+	content map[string]*dedupEntry
+}
+
+type dedupEntry struct {
+	done chan struct{}
+	info *downloadInfo // nil if the download failed
+}
+
+// dedupKey identifies an object's content, or is "" if its ETag is unknown
+func dedupKey(meta *objectMetadata) string {
+	if meta == nil || meta.ETag == "" {
+		return ""
+	}
+	return strings.Trim(meta.ETag, `"`) + "/" + strconv.FormatInt(meta.ContentLength, 10)
+}
+
+// claim returns the entry for key and whether the caller is the first to ask
+// for it, in which case it must download the content and call finish
+func (d *etagDedup) claim(key string) (*dedupEntry, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.content == nil {
+		d.content = make(map[string]*dedupEntry)
+	}
+	if entry, ok := d.content[key]; ok {
+		return entry, false
+	}
+	entry := &dedupEntry{done: make(chan struct{})}
+	d.content[key] = entry
+	return entry, true
+}
+
+// finish records the outcome of a claimed download. A failed one, or one
+// whose content didn't match its ETag, is forgotten so a later object with
+// the same ETag is fetched instead.
+func (d *etagDedup) finish(key string, entry *dedupEntry, info *downloadInfo) {
+	if info != nil && info.integrity == integrityMismatch {
+		info = nil
+	}
+	if info == nil {
+		d.mu.Lock()
+		delete(d.content, key)
+		d.mu.Unlock()
+	}
+	entry.info = info
+	close(entry.done)
+}
+
+// wait returns the claimed download once it has finished
+func (e *dedupEntry) wait() *downloadInfo {
+	<-e.done
+	return e.info
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// DefectDojo "Generic Findings Import" JSON schema
+type dojoReport struct {
+	Findings []dojoFinding `json:"findings"`
+}
+
+type dojoFinding struct {
+	Title            string         `json:"title"`
+	Description      string         `json:"description"`
+	Severity         string         `json:"severity"`
+	Date             string         `json:"date"`
+	Mitigation       string         `json:"mitigation"`
+	Impact           string         `json:"impact"`
+	References       string         `json:"references"`
+	ComponentName    string         `json:"component_name"`
+	UniqueIDFromTool string         `json:"unique_id_from_tool"`
+	StaticFinding    bool           `json:"static_finding"`
+	DynamicFinding   bool           `json:"dynamic_finding"`
+	Active           bool           `json:"active"`
+	Verified         bool           `json:"verified"`
+	Endpoints        []dojoEndpoint `json:"endpoints,omitempty"`
+	StepsToReproduce string         `json:"steps_to_reproduce,omitempty"`
+	VulnIDFromTool   string         `json:"vuln_id_from_tool"`
+}
+
+type dojoEndpoint struct {
+	Protocol string `json:"protocol,omitempty"`
+	Host     string `json:"host"`
+	Port     int    `json:"port,omitempty"`
+	Path     string `json:"path,omitempty"`
+}
+
+var dojoMitigations = map[string]string{
+	findingBucketListable: "Remove public ListBucket permissions from the bucket policy and ACL, and enable S3 Block Public Access.",
+	findingBucketExists:   "Confirm the bucket is owned by the organisation and that its name disclosure is acceptable.",
+	findingObjectPublic:   "Remove public-read ACLs or policy grants from the object, and enable S3 Block Public Access.",
+}
+
+func writeDefectDojoReport(filename string, findings []Finding) error {
+	report := dojoReport{Findings: []dojoFinding{}}
+
+	for _, f := range findings {
+		df := dojoFinding{
+			Title:            fmt.Sprintf("%s: %s", f.Type, f.Bucket),
+			Description:      f.Message,
+			Severity:         dojoSeverity(f.Severity),
+			Date:             f.Time.Format("2006-01-02"),
+			Mitigation:       dojoMitigations[f.Type],
+			Impact:           "Data stored in the bucket may be exposed to anonymous users.",
+			References:       "https://docs.aws.amazon.com/AmazonS3/latest/userguide/access-control-block-public-access.html",
+			ComponentName:    f.Bucket,
+			UniqueIDFromTool: f.Type + ":" + f.URL,
+			VulnIDFromTool:   f.Type,
+			DynamicFinding:   true,
+			Active:           true,
+			Verified:         false,
+		}
+
+		if f.Provider != "" {
+			df.Title = fmt.Sprintf("%s: %s/%s", f.Type, f.Provider, f.Bucket)
+		}
+		if f.Grade != "" {
+			df.Title = fmt.Sprintf("[%s] %s", f.Grade, df.Title)
+			df.Description = fmt.Sprintf("Bucket grade: %s\n\n%s", f.Grade, df.Description)
+		}
+		if f.Source != "" {
+			df.Description += "\n\nSource: " + f.Source
+		}
+		if f.Evidence != "" {
+			df.Description += "\n\n**Evidence**\n\n```\n" + f.Evidence + "\n```"
+		}
+		if f.URL != "" {
+			df.StepsToReproduce = fmt.Sprintf("Send an unauthenticated request to %s", f.URL)
+			if ep, ok := dojoEndpointFromURL(f.URL); ok {
+				df.Endpoints = []dojoEndpoint{ep}
+			}
+		}
+
+		report.Findings = append(report.Findings, df)
+	}
+
+	return writeJSONFile(filename, report)
+}
+
+func dojoEndpointFromURL(raw string) (dojoEndpoint, bool) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Hostname() == "" {
+		return dojoEndpoint{}, false
+	}
+
+	ep := dojoEndpoint{
+		Protocol: u.Scheme,
+		Host:     u.Hostname(),
+		Path:     strings.TrimPrefix(u.EscapedPath(), "/"),
+	}
+	if port := u.Port(); port != "" {
+		fmt.Sscanf(port, "%d", &ep.Port)
+	}
+	return ep, true
+}
+
+// dojoSeverity converts a finding severity into DefectDojo's capitalised form
+func dojoSeverity(severity string) string {
+	switch severity {
+	case "critical":
+		return "Critical"
+	case "high":
+		return "High"
+	case "medium":
+		return "Medium"
+	case "low":
+		return "Low"
+	default:
+		return "Info"
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// scanResults is the --json output format, and the input format for diffs and rechecks
+type scanResults struct {
+	Version  string    `json:"version"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	Findings []Finding `json:"findings"`
+}
+
+type scanDiff struct {
+	Added      []Finding `json:"added"`
+	Remediated []Finding `json:"remediated"`
+	Unchanged  int       `json:"unchanged"`
+}
+
+func writeResultsJSON(filename string, started time.Time, findings []Finding) error {
+	if findings == nil {
+		findings = []Finding{}
+	}
+	return writeJSONFile(filename, scanResults{
+		Version:  version,
+		Started:  started.UTC(),
+		Finished: time.Now().UTC(),
+		Findings: findings,
+	})
+}
+
+func loadResultsJSON(filename string) (*scanResults, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var results scanResults
+	if err := json.Unmarshal(data, &results); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", filename, err)
+	}
+	return &results, nil
+}
+
+// findingKey identifies the same exposure across runs
+func findingKey(f Finding) string {
+	return f.Type + " " + f.URL
+}
+
+func diffFindings(old, new []Finding) scanDiff {
+	oldKeys := make(map[string]bool)
+	for _, f := range old {
+		oldKeys[findingKey(f)] = true
+	}
+	newKeys := make(map[string]bool)
+	for _, f := range new {
+		newKeys[findingKey(f)] = true
+	}
+
+	diff := scanDiff{Added: []Finding{}, Remediated: []Finding{}}
+	for _, f := range new {
+		if oldKeys[findingKey(f)] {
+			diff.Unchanged++
+		} else {
+			diff.Added = append(diff.Added, f)
+		}
+	}
+	for _, f := range old {
+		if !newKeys[findingKey(f)] {
+			diff.Remediated = append(diff.Remediated, f)
+		}
+	}
+
+	byKey := func(list []Finding) func(i, j int) bool {
+		return func(i, j int) bool { return findingKey(list[i]) < findingKey(list[j]) }
+	}
+	sort.Slice(diff.Added, byKey(diff.Added))
+	sort.Slice(diff.Remediated, byKey(diff.Remediated))
+	return diff
+}
+
+func printDiff(diff scanDiff) {
+	fmt.Printf("Newly exposed: %d\n", len(diff.Added))
+	for _, f := range diff.Added {
+		fmt.Printf("\t+ [%s] %s %s\n", f.Severity, f.Type, f.URL)
+	}
+	fmt.Printf("Remediated: %d\n", len(diff.Remediated))
+	for _, f := range diff.Remediated {
+		fmt.Printf("\t- [%s] %s %s\n", f.Severity, f.Type, f.URL)
+	}
+	fmt.Printf("Unchanged: %d\n", diff.Unchanged)
+}
+
+// runDiff implements `bucket_finder diff old.json new.json`
+func runDiff(args []string) int {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	out := fs.String("o", "", "Write the diff as JSON to this file")
+	fs.Usage = func() {
+		fmt.Println("Usage: bucket_finder diff [-o diff.json] old.json new.json")
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return 1
+	}
+
+	old, err := loadResultsJSON(fs.Arg(0))
+	if err != nil {
+		fmt.Printf("Error loading %s: %v\n", fs.Arg(0), err)
+		return 1
+	}
+	current, err := loadResultsJSON(fs.Arg(1))
+	if err != nil {
+		fmt.Printf("Error loading %s: %v\n", fs.Arg(1), err)
+		return 1
+	}
+
+	diff := diffFindings(old.Findings, current.Findings)
+	printDiff(diff)
+
+	if *out != "" {
+		if err := writeJSONFile(*out, diff); err != nil {
+			fmt.Printf("Error writing diff: %v\n", err)
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,497 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Settings for serve and agent mode
+const (
+	agentPollDelay   = 2 * time.Second // how long an agent waits when no batch is ready yet
+	maxAgentFailures = 5               // failed calls in a row before an agent gives up on the coordinator
+	maxResultBody    = 256 << 20
+)
+
+// workBatch is a slice of the candidates leased to one agent. The agent
+// renews the lease while it probes them; one neither renewed nor reported
+// within LeaseSeconds goes to another agent.
+type workBatch struct {
+	ID           int      `json:"id"`
+	Names        []string `json:"names"`
+	LeaseSeconds int      `json:"lease_seconds,omitempty"`
+}
+
+// batchResult is what an agent reports back for a batch it probed
+type batchResult struct {
+	ID       int       `json:"id"`
+	Agent    string    `json:"agent"`
+	Findings []Finding `json:"findings"`
+}
+
+type batchLease struct {
+	batch   *workBatch
+	agent   string
+	expires time.Time
+}
+
+// coordinator hands the candidates out to agents in batches and collects
+// their findings as if it had probed them itself
+type coordinator struct {
+	config  *Config
+	pending chan *workBatch // cut from the feed as agents take them
+
+	mu        sync.Mutex
+	requeued  []*workBatch // leases that timed out, handed out before new batches
+	leased    map[int]*batchLease
+	fed       bool // the feed has been read to the end
+	recording int  // reports accepted but not yet recorded
+	completed int
+	done      chan struct{}
+	closed    bool
+}
+
+// runCoordinator is serve mode's stand-in for processBucketsWithWorkers:
+// agents lease batches of the feed's candidates over HTTP and post back
+// what they found, until every batch has been reported
+func runCoordinator(config *Config, feed *candidateFeed) error {
+	listener, err := net.Listen("tcp", config.listen)
+	if err != nil {
+		fmt.Printf("Could not listen on %s: %v\n", config.listen, err)
+		os.Exit(1)
+	}
+	scheme := "http"
+	if config.listenCert != "" {
+		cert, err := tls.LoadX509KeyPair(config.listenCert, config.listenKey)
+		if err != nil {
+			fmt.Printf("Could not load the coordinator's certificate: %v\n", err)
+			os.Exit(1)
+		}
+		listener = tls.NewListener(listener, &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12})
+		scheme = "https"
+	} else if !listensOnLoopback(config.listen) {
+		fmt.Println("Warning: the coordinator speaks plain HTTP; the token and findings cross the network unencrypted (see --listen-cert)")
+	}
+	c := &coordinator{
+		config:  config,
+		pending: make(chan *workBatch, 4),
+		leased:  make(map[int]*batchLease),
+		done:    make(chan struct{}),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/lease", c.handleLease)
+	mux.HandleFunc("/v1/renew", c.handleRenew)
+	mux.HandleFunc("/v1/results", c.handleResults)
+	server := &http.Server{Handler: c.authorize(mux), ReadHeaderTimeout: 10 * time.Second}
+	go server.Serve(listener)
+	fmt.Printf("Coordinator listening on %s://%s, handing out batches of %d candidates\n", scheme, listener.Addr(), config.batchSize)
+
+	// Batches are cut from the feed as agents take them, until it runs out
+	// or the scan is stopped
+	feed.stop = config.intake.Done()
+	fed := make(chan error, 1)
+	go func() {
+		batch := &workBatch{ID: 1}
+		err := feed.each(func(name string) {
+			batch.Names = append(batch.Names, name)
+			if len(batch.Names) >= config.batchSize {
+				c.queue(batch)
+				batch = &workBatch{ID: batch.ID + 1}
+			}
+		})
+		if len(batch.Names) > 0 {
+			c.queue(batch)
+		}
+		c.mu.Lock()
+		c.fed = true
+		c.checkDone()
+		c.mu.Unlock()
+		fed <- err
+	}()
+
+	select {
+	case <-c.done:
+		err = <-fed
+	case <-config.intake.Done():
+	}
+
+	// Agents still polling are told the scan is over before the server goes
+	c.mu.Lock()
+	c.closeDone()
+	c.mu.Unlock()
+	time.Sleep(agentPollDelay + time.Second)
+	server.Close()
+	return err
+}
+
+func (c *coordinator) queue(batch *workBatch) {
+	select {
+	case c.pending <- batch:
+	case <-c.config.intake.Done():
+	}
+}
+
+// checkDone closes done once every batch has been reported; c.mu is held
+func (c *coordinator) checkDone() {
+	if c.fed && len(c.pending) == 0 && len(c.requeued) == 0 && len(c.leased) == 0 && c.recording == 0 {
+		c.closeDone()
+	}
+}
+
+func (c *coordinator) closeDone() {
+	if !c.closed {
+		c.closed = true
+		close(c.done)
+	}
+}
+
+// listensOnLoopback reports whether addr only takes connections from this
+// machine, where serve may go without a token
+func listensOnLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	return err == nil && isLoopbackHost(host)
+}
+
+// authorize turns away agents without the --token, when one is set
+func (c *coordinator) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		want := "Bearer " + c.config.token
+		if c.config.token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(want)) != 1 {
+			http.Error(w, "bad token", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleLease gives the agent a batch, asks it to come back later (204) if
+// none is ready, or tells it the scan is over (410)
+func (c *coordinator) handleLease(w http.ResponseWriter, r *http.Request) {
+	agent := r.URL.Query().Get("agent")
+
+	c.mu.Lock()
+	c.expireLeases()
+	var batch *workBatch
+	if n := len(c.requeued); n > 0 {
+		batch = c.requeued[n-1]
+		c.requeued = c.requeued[:n-1]
+	} else if !c.closed {
+		select {
+		case batch = <-c.pending:
+		default:
+		}
+	}
+	if batch != nil {
+		batch.LeaseSeconds = int(c.config.leaseTimeout / time.Second)
+		c.leased[batch.ID] = &batchLease{batch: batch, agent: agent, expires: time.Now().Add(c.config.leaseTimeout)}
+	}
+	closed := c.closed
+	c.mu.Unlock()
+
+	switch {
+	case batch != nil:
+		if c.config.verbose {
+			fmt.Printf("Batch %d (%d names) leased to %s\n", batch.ID, len(batch.Names), agent)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(batch)
+	case closed:
+		http.Error(w, "scan finished", http.StatusGone)
+	default:
+		w.Header().Set("Retry-After", strconv.Itoa(int(agentPollDelay/time.Second)))
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// handleRenew extends an agent's lease on a batch it is still probing, or
+// tells it the batch went to another agent (410)
+func (c *coordinator) handleRenew(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST to renew a lease", http.StatusMethodNotAllowed)
+		return
+	}
+	id, _ := strconv.Atoi(r.URL.Query().Get("id"))
+	agent := r.URL.Query().Get("agent")
+
+	c.mu.Lock()
+	c.expireLeases()
+	lease, ok := c.leased[id]
+	if ok && lease.agent == agent {
+		lease.expires = time.Now().Add(c.config.leaseTimeout)
+	}
+	c.mu.Unlock()
+
+	if !ok || lease.agent != agent {
+		http.Error(w, "lease expired", http.StatusGone)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// expireLeases takes back the batches whose agents went quiet; c.mu is held
+func (c *coordinator) expireLeases() {
+	now := time.Now()
+	for id, lease := range c.leased {
+		if now.After(lease.expires) {
+			delete(c.leased, id)
+			c.requeued = append(c.requeued, lease.batch)
+			msg := fmt.Sprintf("Batch %d leased to %s timed out; handing it to another agent", id, lease.agent)
+			fmt.Println(msg)
+			if c.config.logger != nil {
+				c.config.logger.Println(msg)
+			}
+		}
+	}
+}
+
+// handleResults records an agent's findings for a batch. A batch is only
+// counted once, so a late report of one already handed to another agent is
+// dropped.
+func (c *coordinator) handleResults(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST a batch result", http.StatusMethodNotAllowed)
+		return
+	}
+	var result batchResult
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxResultBody)).Decode(&result); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c.mu.Lock()
+	batch := c.take(result.ID)
+	if batch != nil {
+		c.recording++
+		c.completed++
+	}
+	completed := c.completed
+	c.mu.Unlock()
+	if batch == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	for _, f := range result.Findings {
+		recordFinding(context.Background(), c.config, f)
+	}
+	msg := fmt.Sprintf("Batch %d done by %s: %d names, %d finding(s) (%d batches done)",
+		result.ID, result.Agent, len(batch.Names), len(result.Findings), completed)
+	fmt.Println(msg)
+	if c.config.logger != nil {
+		c.config.logger.Println(msg)
+	}
+
+	c.mu.Lock()
+	c.recording--
+	c.checkDone()
+	c.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// take removes the batch with id from the outstanding ones, whether its
+// lease is current or ran out, or returns nil if it was already reported;
+// c.mu is held
+func (c *coordinator) take(id int) *workBatch {
+	if lease, ok := c.leased[id]; ok {
+		delete(c.leased, id)
+		return lease.batch
+	}
+	for i, batch := range c.requeued {
+		if batch.ID == id {
+			c.requeued = append(c.requeued[:i], c.requeued[i+1:]...)
+			return batch
+		}
+	}
+	return nil
+}
+
+// agentClient talks to the coordinator directly, not through the scan's
+// proxy, Tor or stealth settings, though with its TLS settings (--ca-file,
+// --client-cert)
+type agentClient struct {
+	name   string
+	base   string
+	token  string
+	client *http.Client
+}
+
+// runAgent leases batches from the coordinator, probes them with this
+// machine's settings and reports the findings back, until the coordinator
+// has no more work. It returns the exit status.
+func runAgent(config *Config) int {
+	host, _ := os.Hostname()
+	a := &agentClient{
+		name:  fmt.Sprintf("%s-%d", host, os.Getpid()),
+		base:  strings.TrimRight(config.coordinatorURL, "/"),
+		token: config.token,
+		client: &http.Client{
+			Timeout:   5 * time.Minute,
+			Transport: &http.Transport{TLSClientConfig: config.tlsConfig},
+		},
+	}
+	fmt.Printf("Agent %s taking work from %s\n", a.name, a.base)
+
+	failures := 0
+	for config.intake.Err() == nil {
+		batch, err := a.lease()
+		if err != nil {
+			if failures++; failures >= maxAgentFailures || err == errBadToken {
+				fmt.Printf("Giving up on the coordinator: %v\n", err)
+				return 1
+			}
+			fmt.Printf("Could not reach the coordinator: %v\n", err)
+			sleepUnlessStopped(config, retryDelay(failures))
+			continue
+		}
+		failures = 0
+		if batch == nil {
+			sleepUnlessStopped(config, agentPollDelay)
+			continue
+		}
+		if batch.ID == 0 {
+			fmt.Println("The coordinator has no more work")
+			return 0
+		}
+
+		if config.verbose {
+			fmt.Printf("Probing batch %d (%d names)\n", batch.ID, len(batch.Names))
+		}
+		config.findings = &findingStore{}
+		stopRenewing := a.keepLease(batch)
+		err = processBucketsWithWorkers(config, &candidateFeed{names: batch.Names})
+		stopRenewing()
+		if err != nil {
+			fmt.Printf("Error probing batch %d: %v\n", batch.ID, err)
+			continue
+		}
+		if config.intake.Err() != nil {
+			fmt.Printf("Batch %d left unfinished; the coordinator will hand it to another agent\n", batch.ID)
+			break
+		}
+		if err := a.report(batchResult{ID: batch.ID, Agent: a.name, Findings: config.findings.all()}); err != nil {
+			fmt.Printf("Could not report batch %d: %v\n", batch.ID, err)
+		}
+	}
+	if config.scan.Err() != nil {
+		return 130
+	}
+	return 0
+}
+
+var errBadToken = errors.New("the coordinator rejected the token")
+
+// lease asks for a batch: nil means none is ready yet, and one with ID 0
+// that the scan is over
+func (a *agentClient) lease() (*workBatch, error) {
+	resp, err := a.do("GET", "/v1/lease?agent="+url.QueryEscape(a.name), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		batch := &workBatch{}
+		if err := json.NewDecoder(resp.Body).Decode(batch); err != nil {
+			return nil, err
+		}
+		return batch, nil
+	case http.StatusNoContent:
+		return nil, nil
+	case http.StatusGone:
+		return &workBatch{}, nil
+	case http.StatusUnauthorized:
+		return nil, errBadToken
+	}
+	return nil, fmt.Errorf("lease returned %s", resp.Status)
+}
+
+// keepLease renews the lease on batch every third of its term until the
+// returned func is called
+func (a *agentClient) keepLease(batch *workBatch) func() {
+	term := time.Duration(batch.LeaseSeconds) * time.Second
+	if term <= 0 {
+		return func() {}
+	}
+	stop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(term / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-stop:
+				return
+			}
+			resp, err := a.do("POST", fmt.Sprintf("/v1/renew?id=%d&agent=%s", batch.ID, url.QueryEscape(a.name)), nil)
+			if err != nil {
+				fmt.Printf("Could not renew the lease on batch %d: %v\n", batch.ID, err)
+				continue
+			}
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusGone {
+				fmt.Printf("The lease on batch %d ran out; the coordinator has handed it to another agent\n", batch.ID)
+				return
+			}
+		}
+	}()
+	return func() { close(stop) }
+}
+
+// report posts a batch's findings, retrying a few times: once the lease
+// runs out the batch is probed again elsewhere anyway
+func (a *agentClient) report(result batchResult) error {
+	body, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	for attempt := 1; ; attempt++ {
+		resp, err := a.do("POST", "/v1/results", body)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode < 300 {
+				return nil
+			}
+			err = fmt.Errorf("results returned %s", resp.Status)
+		}
+		if attempt >= 3 {
+			return err
+		}
+		time.Sleep(retryDelay(attempt))
+	}
+}
+
+func (a *agentClient) do(method, path string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(method, a.base+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if a.token != "" {
+		req.Header.Set("Authorization", "Bearer "+a.token)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return a.client.Do(req)
+}
+
+// sleepUnlessStopped waits for d, or less if the scan is stopped meanwhile
+func sleepUnlessStopped(config *Config, d time.Duration) {
+	select {
+	case <-time.After(d):
+	case <-config.intake.Done():
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// downloadFilter picks which readable objects --download fetches
+type downloadFilter struct {
+	keys    *keyFilter
+	minSize int64
+	maxSize int64 // 0 for no limit
+}
+
+// newDownloadFilter builds a filter from --dl-match, --dl-ext, --dl-min-size
+// and --dl-max-size, or returns nil if none is set
+func newDownloadFilter(pattern, extensions, minSize, maxSize string) (*downloadFilter, error) {
+	if pattern == "" && extensions == "" && minSize == "" && maxSize == "" {
+		return nil, nil
+	}
+
+	keys, err := newKeyFilter(pattern, extensions)
+	if err != nil {
+		return nil, err
+	}
+	f := &downloadFilter{keys: keys}
+	if minSize != "" {
+		if f.minSize, err = parseByteSize(minSize); err != nil {
+			return nil, fmt.Errorf("bad --dl-min-size: %v", err)
+		}
+	}
+	if maxSize != "" {
+		if f.maxSize, err = parseByteSize(maxSize); err != nil {
+			return nil, fmt.Errorf("bad --dl-max-size: %v", err)
+		}
+	}
+	return f, nil
+}
+
+// match reports whether an object of size bytes should be downloaded. Size
+// limits don't apply when the size is unknown (-1).
+func (f *downloadFilter) match(key string, size int64) bool {
+	if f == nil {
+		return true
+	}
+	if !f.keys.match(key) {
+		return false
+	}
+	if size < 0 {
+		return true
+	}
+	return size >= f.minSize && (f.maxSize == 0 || size <= f.maxSize)
+}
+
+// parseByteSize reads a size such as 500, 10KB, 1.5MB or 5GB. Units are
+// binary: 1KB is 1024 bytes.
+func parseByteSize(size string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(size))
+	units := []struct {
+		suffix string
+		size   float64
+	}{
+		{"TIB", 1 << 40}, {"GIB", 1 << 30}, {"MIB", 1 << 20}, {"KIB", 1 << 10},
+		{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+		{"T", 1 << 40}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10},
+		{"B", 1},
+	}
+
+	multiplier := 1.0
+	for _, unit := range units {
+		if strings.HasSuffix(s, unit.suffix) {
+			s = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix))
+			multiplier = unit.size
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", size)
+	}
+	return int64(n * multiplier), nil
+}
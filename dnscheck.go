@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// dnsPrecheck triages candidates at DNS speed before they are probed over
+// HTTP. AWS answers <bucket>.s3.amazonaws.com for every name, but names
+// without a bucket all resolve to the same canonical host, while buckets in
+// other regions point at their regional endpoint.
+type dnsPrecheck struct {
+	resolver *dnsCache
+	// Canonical name AWS gives names that have no bucket
+	missing string
+	dropped atomic.Int64
+}
+
+// newDNSPrecheck learns the no-such-bucket answer by resolving a random
+// name, unless it is given
+func newDNSPrecheck(resolver *dnsCache, missing string) (*dnsPrecheck, error) {
+	d := &dnsPrecheck{resolver: resolver, missing: strings.TrimSuffix(strings.ToLower(missing), ".")}
+	if d.missing != "" {
+		return d, nil
+	}
+
+	token := make([]byte, 12)
+	rand.Read(token)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	cname, err := d.resolver.LookupCNAME(ctx, "bucket-finder-"+hex.EncodeToString(token)+".s3.amazonaws.com")
+	if err != nil {
+		return nil, fmt.Errorf("could not learn the no-such-bucket answer: %v", err)
+	}
+	d.missing = strings.TrimSuffix(strings.ToLower(cname), ".")
+	return d, nil
+}
+
+// mayExist reports whether DNS leaves open that bucketName exists. Lookup
+// failures other than a missing name give the benefit of the doubt.
+func (d *dnsPrecheck) mayExist(ctx context.Context, bucketName string) bool {
+	cname, err := d.resolver.LookupCNAME(ctx, strings.ToLower(bucketName)+".s3.amazonaws.com")
+	if err != nil {
+		var dnsErr *net.DNSError
+		return !(errors.As(err, &dnsErr) && dnsErr.IsNotFound)
+	}
+	return strings.TrimSuffix(strings.ToLower(cname), ".") != d.missing
+}
+
+// filter passes the names that may exist from in to out, with workers
+// lookups at a time, and returns once in is closed and drained
+func (d *dnsPrecheck) filter(in <-chan string, out chan<- string, workers int) {
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range in {
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				ok := d.mayExist(ctx, name)
+				cancel()
+				if ok {
+					out <- name
+				} else {
+					d.dropped.Add(1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// newDoHResolver returns a resolver that sends its queries to a DNS-over-
+// HTTPS endpoint (RFC 8484), so lookups aren't visible on the local
+// network. The endpoint's own name is resolved by the system resolver.
+func newDoHResolver(endpoint string, proxy *url.URL) (*net.Resolver, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("expected an https:// URL, got %q", endpoint)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if proxy != nil {
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	client := &http.Client{Timeout: 10 * time.Second, Transport: transport}
+
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return &dohConn{ctx: ctx, client: client, endpoint: u.String()}, nil
+		},
+	}, nil
+}
+
+// dohConn stands in for the TCP connection Go's resolver would send a query
+// on. Queries arrive as length-prefixed DNS messages, the way they are sent
+// over TCP; each is POSTed to the endpoint and its answer read back the same
+// way.
+type dohConn struct {
+	ctx      context.Context
+	client   *http.Client
+	endpoint string
+	query    bytes.Buffer
+	answer   bytes.Reader
+}
+
+func (c *dohConn) Write(b []byte) (int, error) {
+	return c.query.Write(b)
+}
+
+func (c *dohConn) Read(b []byte) (int, error) {
+	if c.answer.Len() == 0 {
+		if err := c.exchange(); err != nil {
+			return 0, err
+		}
+	}
+	return c.answer.Read(b)
+}
+
+// exchange sends the buffered query and buffers the answer
+func (c *dohConn) exchange() error {
+	data := c.query.Bytes()
+	if len(data) < 2 || len(data) < 2+int(binary.BigEndian.Uint16(data)) {
+		return io.ErrUnexpectedEOF
+	}
+	n := int(binary.BigEndian.Uint16(data))
+	msg := append([]byte(nil), data[2:2+n]...)
+	c.query.Next(2 + n)
+
+	req, err := http.NewRequestWithContext(c.ctx, "POST", c.endpoint, bytes.NewReader(msg))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("DoH server returned %s", resp.Status)
+	}
+	answer, err := io.ReadAll(io.LimitReader(resp.Body, 65535))
+	if err != nil {
+		return err
+	}
+
+	framed := make([]byte, 2+len(answer))
+	binary.BigEndian.PutUint16(framed, uint16(len(answer)))
+	copy(framed[2:], answer)
+	c.answer.Reset(framed)
+	return nil
+}
+
+func (c *dohConn) Close() error                       { return nil }
+func (c *dohConn) LocalAddr() net.Addr                { return dohAddr{} }
+func (c *dohConn) RemoteAddr() net.Addr               { return dohAddr{} }
+func (c *dohConn) SetDeadline(t time.Time) error      { return nil }
+func (c *dohConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *dohConn) SetWriteDeadline(t time.Time) error { return nil }
+
+type dohAddr struct{}
+
+func (dohAddr) Network() string { return "doh" }
+func (dohAddr) String() string  { return "doh" }
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// Multi-label public suffixes common enough to be worth knowing without a
+// --psl file. Any single-label TLD is always treated as a public suffix.
+var builtinPublicSuffixes = []string{
+	"co.uk", "org.uk", "me.uk", "ltd.uk", "plc.uk", "ac.uk", "gov.uk", "nhs.uk",
+	"com.au", "net.au", "org.au", "edu.au", "gov.au", "co.nz", "org.nz", "net.nz", "govt.nz",
+	"co.jp", "ne.jp", "or.jp", "ac.jp", "go.jp", "co.kr", "or.kr", "ne.kr",
+	"com.cn", "net.cn", "org.cn", "gov.cn", "com.hk", "org.hk", "com.tw", "org.tw",
+	"com.sg", "edu.sg", "com.my", "com.ph", "com.vn", "co.th", "co.id", "or.id",
+	"co.in", "net.in", "org.in", "firm.in", "gen.in", "com.pk", "com.bd", "com.np", "com.lk",
+	"com.br", "net.br", "org.br", "gov.br", "com.mx", "org.mx", "com.ar", "com.co", "com.pe",
+	"com.uy", "com.ve", "com.ec", "cl.cl", "co.za", "org.za", "co.ke", "com.ng", "com.eg",
+	"co.il", "org.il", "ac.il", "com.tr", "org.tr", "com.sa", "com.qa", "co.ae", "com.kw",
+	"com.ua", "com.pl", "net.pl", "org.pl", "co.at", "or.at", "com.es", "com.pt", "com.gr",
+	"com.cy", "com.mt", "co.hu", "com.ro",
+}
+
+// Public suffixes, wildcard rules ("*.ck") and exceptions ("!www.ck"),
+// seeded from builtinPublicSuffixes and replaced by --psl
+var publicSuffixes = newSuffixSet(builtinPublicSuffixes)
+
+type suffixSet struct {
+	rules      map[string]bool
+	wildcards  map[string]bool
+	exceptions map[string]bool
+}
+
+func newSuffixSet(rules []string) *suffixSet {
+	s := &suffixSet{
+		rules:      make(map[string]bool),
+		wildcards:  make(map[string]bool),
+		exceptions: make(map[string]bool),
+	}
+	for _, rule := range rules {
+		rule = strings.ToLower(strings.TrimSpace(rule))
+		switch {
+		case strings.HasPrefix(rule, "!"):
+			s.exceptions[rule[1:]] = true
+		case strings.HasPrefix(rule, "*."):
+			s.wildcards[rule[2:]] = true
+		case rule != "":
+			s.rules[rule] = true
+		}
+	}
+	return s
+}
+
+// loadPublicSuffixList reads a public_suffix_list.dat from publicsuffix.org
+func loadPublicSuffixList(filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var rules []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "//") {
+			continue
+		}
+		// Rules end at the first whitespace
+		rules = append(rules, strings.Fields(line)[0])
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	publicSuffixes = newSuffixSet(rules)
+	return nil
+}
+
+// isPublicSuffix reports whether labels, joined with dots, form a public suffix
+func (s *suffixSet) isPublicSuffix(labels []string) bool {
+	name := strings.Join(labels, ".")
+	if s.exceptions[name] {
+		return false
+	}
+	if len(labels) == 1 || s.rules[name] {
+		return true
+	}
+	return s.wildcards[strings.Join(labels[1:], ".")]
+}
+
+// splitDomain splits a host name into the subdomain labels, the label
+// registered under the public suffix, and the public suffix itself:
+// "app.staging.example.co.uk" -> [app staging], "example", "co.uk"
+func splitDomain(host string) (subdomains []string, name, suffix string) {
+	labels := strings.Split(strings.Trim(strings.ToLower(host), "."), ".")
+	if len(labels) < 2 {
+		return nil, host, ""
+	}
+
+	// The longest matching suffix wins, but something has to be registered under it
+	for i := 1; i < len(labels); i++ {
+		if publicSuffixes.isPublicSuffix(labels[i:]) {
+			return labels[:i-1], labels[i-1], strings.Join(labels[i:], ".")
+		}
+	}
+	return labels[:len(labels)-2], labels[len(labels)-2], labels[len(labels)-1]
+}
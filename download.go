@@ -0,0 +1,234 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// downloadJob is a readable object waiting to be downloaded
+type downloadJob struct {
+	provider   string
+	fileURL    string
+	bucketName string
+	key        string
+	depth      int
+	size       int64 // -1 if unknown
+	meta       *objectMetadata
+}
+
+// downloadPool downloads objects on workers of its own, so large files
+// don't hold up the workers probing buckets
+type downloadPool struct {
+	jobs chan downloadJob
+	wg   sync.WaitGroup
+
+	// Caps from --max-download and --max-files-per-bucket, 0 for none
+	maxBytes     int64
+	maxPerBucket int
+
+	dedup etagDedup
+
+	// Per-bucket reports are written once their downloads are done
+	reports *bucketReportStore
+
+	mu        sync.Mutex
+	bytes     int64
+	perBucket map[string]int
+	exhausted bool
+}
+
+func startDownloadPool(config *Config, workers int) *downloadPool {
+	if workers < 1 {
+		workers = 1
+	}
+	p := &downloadPool{
+		jobs:         make(chan downloadJob, workers*100),
+		maxBytes:     config.maxDownloadBytes,
+		maxPerBucket: config.maxFilesPerBucket,
+		perBucket:    make(map[string]int),
+		reports:      config.bucketReports,
+	}
+	for i := 0; i < workers; i++ {
+		p.wg.Add(1)
+		go func(workerId int) {
+			defer p.wg.Done()
+			for job := range p.jobs {
+				p.download(config, job, workerId)
+				if p.reports == nil {
+					continue
+				}
+				if err := p.reports.release(job.bucketName); err != nil {
+					fmt.Printf("[Download %d] Error writing report for %s: %v\n", workerId, job.bucketName, err)
+				}
+			}
+		}(i)
+	}
+	return p
+}
+
+// admit decides whether an object of size bytes (-1 if unknown) may still be
+// downloaded from bucket, and if so reserves its share of the caps. Objects
+// of unknown size are counted once downloaded.
+func (p *downloadPool) admit(config *Config, bucket string, size int64) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.maxPerBucket > 0 && p.perBucket[bucket] >= p.maxPerBucket {
+		return false
+	}
+	if p.maxBytes > 0 && (p.bytes >= p.maxBytes || size > 0 && p.bytes+size > p.maxBytes) {
+		if !p.exhausted && p.bytes >= p.maxBytes {
+			p.exhausted = true
+			msg := fmt.Sprintf("Download budget of %s used up; no more files will be downloaded", formatSize(p.maxBytes))
+			fmt.Println(msg)
+			if config.logger != nil {
+				config.logger.Println(msg)
+			}
+		}
+		return false
+	}
+
+	p.perBucket[bucket]++
+	if size > 0 {
+		p.bytes += size
+	}
+	return true
+}
+
+// settle corrects the reservation for job once the number of bytes actually
+// written is known
+func (p *downloadPool) settle(job downloadJob, written int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if job.size > 0 {
+		p.bytes -= job.size
+	}
+	p.bytes += written
+}
+
+// queue hands an object to the pool, waiting while it is full
+func (p *downloadPool) queue(job downloadJob) {
+	if p.reports != nil {
+		p.reports.hold(job.bucketName)
+	}
+	p.jobs <- job
+}
+
+// wait lets the queued downloads finish
+func (p *downloadPool) wait() {
+	close(p.jobs)
+	p.wg.Wait()
+}
+
+func (p *downloadPool) download(config *Config, job downloadJob, workerId int) {
+	workerPrefix := ""
+	if config.verbose {
+		workerPrefix = fmt.Sprintf("[Download %d] ", workerId)
+	}
+
+	// Downloads are not bound by the budget of the bucket they came from,
+	// and those still queued when the scan is interrupted or out of time
+	// are skipped
+	if config.intake.Err() != nil {
+		p.settle(job, 0)
+		return
+	}
+	ctx := withProvider(config.scan, job.provider)
+
+	// Content already downloaded under another key is not fetched again
+	var info *downloadInfo
+	if key := dedupKey(job.meta); key != "" {
+		entry, first := p.dedup.claim(key)
+		if first {
+			defer func() { p.dedup.finish(key, entry, info) }()
+		} else if earlier := entry.wait(); earlier != nil {
+			p.settle(job, 0)
+			duplicate := *earlier
+			recordDownload(config, job, &duplicate, "duplicate")
+			if config.verbose {
+				fmt.Printf("%s<Duplicate> %s (same content as %s)\n", workerPrefix, job.fileURL, duplicate.path)
+			}
+			reportSecrets(ctx, config, job, &duplicate)
+			return
+		}
+	}
+
+	// Keep the copy an earlier run left if the object hasn't changed since
+	if !config.redownload && config.mirror == nil {
+		if info = existingDownload(config, job); info != nil {
+			p.settle(job, 0)
+			recordDownload(config, job, info, "unchanged")
+			if config.verbose {
+				fmt.Printf("%s<Unchanged> %s -> %s\n", workerPrefix, job.fileURL, info.path)
+			}
+			reportSecrets(ctx, config, job, info)
+			return
+		}
+	}
+
+	var readable bool
+	status, label := "downloaded", "Downloaded"
+	if config.mirror != nil {
+		status, label = "mirrored", "Mirrored"
+		info, readable = config.mirror.copy(ctx, config, job)
+	} else {
+		// Large objects are fetched in parallel ranges where the server allows it
+		if fileName, ok := splitDownload(config, job); ok {
+			info, readable = downloadRanged(ctx, config, job, fileName)
+		}
+		if info == nil && ctx.Err() == nil {
+			info, _, readable = downloadFile(ctx, config, job.fileURL, job.bucketName, job.key, job.depth)
+		}
+	}
+	if info != nil {
+		p.settle(job, info.size)
+	} else {
+		p.settle(job, 0)
+	}
+
+	var msg string
+	switch {
+	case info != nil:
+		msg = fmt.Sprintf("%s<%s> %s -> %s", workerPrefix, label, job.fileURL, info.path)
+		if info.integrity == integrityVerified {
+			msg += " (ETag verified)"
+		}
+		recordDownload(config, job, info, status)
+		switch info.content {
+		case contentNew:
+			msg += " (new content)"
+		case contentSeen:
+			msg += " (previously seen)"
+		}
+	case ctx.Err() != nil:
+		msg = fmt.Sprintf("%sStopped downloading %s: interrupted", workerPrefix, job.fileURL)
+	case readable:
+		msg = fmt.Sprintf("%sCould not save %s", workerPrefix, job.fileURL)
+	default:
+		msg = fmt.Sprintf("%sCould not download %s", workerPrefix, job.fileURL)
+	}
+	fmt.Println(msg)
+	if config.logger != nil {
+		config.logger.Println(msg)
+	}
+
+	if info != nil && info.integrity == integrityMismatch {
+		reportIntegrityMismatch(ctx, config, job, info, info.etag)
+	}
+	if info != nil {
+		reportSecrets(ctx, config, job, info)
+	}
+}
+
+// recordDownload adds a saved object to its finding, the manifest and the
+// hash index
+func recordDownload(config *Config, job downloadJob, info *downloadInfo, status string) {
+	if config.hashIndex != nil {
+		info.content = config.hashIndex.record(job.fileURL, info)
+	}
+	config.findings.addDownload(job.fileURL, info)
+	config.metrics.addDownload(status, info.size)
+	if config.manifest != nil {
+		config.manifest.add(job, info, status)
+	}
+}
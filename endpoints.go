@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// EndpointMap is the on-disk format of the --endpoints file. Endpoints add
+// or override the S3 endpoint used for a region code; shortcuts add or
+// override --region shortcuts.
+//
+//	{
+//	  "endpoints": {
+//	    "eu-west-9": "https://s3.eu-west-9.amazonaws.com",
+//	    "wasabi":    "https://s3.wasabisys.com"
+//	  },
+//	  "shortcuts": {"wa": "wasabi"}
+//	}
+type EndpointMap struct {
+	Endpoints map[string]string `json:"endpoints"`
+	Shortcuts map[string]string `json:"shortcuts"`
+}
+
+// Region endpoints loaded from --endpoints, checked before the built-in list
+var regionEndpoints = map[string]string{}
+
+// loadEndpointMap merges an --endpoints file into the region tables. New
+// AWS regions also join the --all-regions sweep; other clouds don't.
+func loadEndpointMap(filename string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	var em EndpointMap
+	if err := json.Unmarshal(data, &em); err != nil {
+		return fmt.Errorf("parsing %s: %v", filename, err)
+	}
+
+	for region, endpoint := range em.Endpoints {
+		region = strings.ToLower(strings.TrimSpace(region))
+		u, err := url.Parse(endpoint)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("endpoint for %q is not a URL: %q", region, endpoint)
+		}
+
+		regionEndpoints[region] = strings.TrimRight(endpoint, "/")
+		if strings.HasSuffix(u.Hostname(), ".amazonaws.com") && !containsString(awsRegions, region) {
+			awsRegions = append(awsRegions, region)
+		}
+	}
+
+	for shortcut, region := range em.Shortcuts {
+		region = strings.ToLower(strings.TrimSpace(region))
+		if _, ok := regionEndpoints[region]; !ok && !containsString(awsRegions, region) {
+			return fmt.Errorf("shortcut %q points at unknown region %q", shortcut, region)
+		}
+		legacyRegions[strings.ToLower(strings.TrimSpace(shortcut))] = region
+	}
+
+	return nil
+}
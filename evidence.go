@@ -0,0 +1,167 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// httpExchange is a raw request/response pair kept as evidence
+type httpExchange struct {
+	request  string
+	response []byte
+}
+
+type evidenceIndexEntry struct {
+	ID       string `json:"id"`
+	Bucket   string `json:"bucket"`
+	Type     string `json:"type"`
+	Severity string `json:"severity"`
+	URL      string `json:"url"`
+	Dir      string `json:"dir"`
+}
+
+type evidenceManifestEntry struct {
+	File   string `json:"file"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// evidenceStore holds the exchanges of requests that are still being
+// processed and writes a bundle per finding under dir
+type evidenceStore struct {
+	dir       string
+	exchanges sync.Map
+
+	mu    sync.Mutex
+	index []evidenceIndexEntry
+}
+
+var unsafePathChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+func newEvidenceStore(dir string) (*evidenceStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &evidenceStore{dir: dir}, nil
+}
+
+// recordExchange keeps the raw request and response for url until a finding
+// claims it or the caller forgets it. A bucket's URL with or without the
+// trailing slash refers to the same exchange.
+func (e *evidenceStore) recordExchange(url string, resp *http.Response, body []byte) {
+	req := resp.Request
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s %s\r\n", req.Method, req.URL.RequestURI(), req.Proto)
+	fmt.Fprintf(&b, "Host: %s\r\n", req.URL.Host)
+	req.Header.Write(&b)
+	b.WriteString("\r\n")
+
+	head, err := httputil.DumpResponse(resp, false)
+	if err != nil {
+		head = []byte(resp.Status + "\r\n\r\n")
+	}
+
+	e.exchanges.Store(strings.TrimSuffix(url, "/"), &httpExchange{
+		request:  b.String(),
+		response: append(head, body...),
+	})
+}
+
+func (e *evidenceStore) forget(url string) {
+	e.exchanges.Delete(strings.TrimSuffix(url, "/"))
+}
+
+// writeBundle stores everything known about a finding in its own folder
+func (e *evidenceStore) writeBundle(f Finding) error {
+	e.mu.Lock()
+	id := fmt.Sprintf("%05d", len(e.index)+1)
+	dirName := id + "-" + f.Type + "-" + unsafePathChars.ReplaceAllString(f.Bucket, "_")
+	e.index = append(e.index, evidenceIndexEntry{
+		ID:       id,
+		Bucket:   f.Bucket,
+		Type:     f.Type,
+		Severity: f.Severity,
+		URL:      f.URL,
+		Dir:      dirName,
+	})
+	e.mu.Unlock()
+
+	dir := filepath.Join(e.dir, dirName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	if err := writeJSONFile(filepath.Join(dir, "finding.json"), f); err != nil {
+		return err
+	}
+
+	if v, ok := e.exchanges.LoadAndDelete(strings.TrimSuffix(f.URL, "/")); ok {
+		ex := v.(*httpExchange)
+		if err := os.WriteFile(filepath.Join(dir, "request.txt"), []byte(ex.request), 0644); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, "response.txt"), ex.response, 0644); err != nil {
+			return err
+		}
+
+		// Keep the parsed listing alongside the raw XML
+		if f.Type == findingBucketListable {
+			if i := strings.Index(string(ex.response), "\r\n\r\n"); i >= 0 {
+				var listing ListBucketResult
+				if err := xml.Unmarshal(ex.response[i+4:], &listing); err == nil {
+					if err := writeJSONFile(filepath.Join(dir, "listing.json"), listing); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+
+	return writeEvidenceManifest(dir)
+}
+
+// writeEvidenceManifest hashes every file in a bundle so it can be verified later
+func writeEvidenceManifest(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	var manifest []evidenceManifestEntry
+	for _, entry := range entries {
+		if entry.IsDir() || entry.Name() == "manifest.json" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		manifest = append(manifest, evidenceManifestEntry{
+			File:   entry.Name(),
+			Size:   int64(len(data)),
+			SHA256: hex.EncodeToString(sum[:]),
+		})
+	}
+
+	return writeJSONFile(filepath.Join(dir, "manifest.json"), manifest)
+}
+
+func (e *evidenceStore) writeIndex() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	index := append([]evidenceIndexEntry{}, e.index...)
+	sort.Slice(index, func(i, j int) bool { return index[i].ID < index[j].ID })
+	return writeJSONFile(filepath.Join(e.dir, "index.json"), index)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// loadExclusions gathers the --exclude patterns and the lines of
+// --exclude-file. Patterns are globs such as "acme-corp-*"; plain names
+// match only themselves.
+func loadExclusions(patterns, filename string) ([]string, error) {
+	var exclusions []string
+	for _, pattern := range strings.Split(patterns, ",") {
+		exclusions = append(exclusions, pattern)
+	}
+	if filename != "" {
+		lines, err := loadWordlist(filename)
+		if err != nil {
+			return nil, err
+		}
+		exclusions = append(exclusions, lines...)
+	}
+
+	var cleaned []string
+	for _, pattern := range exclusions {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == "" || strings.HasPrefix(pattern, "#") {
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("bad exclude pattern %q", pattern)
+		}
+		cleaned = append(cleaned, pattern)
+	}
+	return cleaned, nil
+}
+
+// isExcluded reports whether name matches any exclusion pattern
+func isExcluded(name string, exclusions []string) bool {
+	name = strings.ToLower(name)
+	for _, pattern := range exclusions {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
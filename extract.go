@@ -0,0 +1,52 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// extractBucketNames finds the bucket and container names in storage URLs
+// anywhere in text, and routes each to the provider whose URL it was in.
+// Azure containers are named account/container. JSON-escaped slashes, as in
+// HAR files and JS bundles, are undone first.
+func extractBucketNames(text string, routes *candidateRoutes) []string {
+	text = strings.ToLower(strings.ReplaceAll(text, `\/`, "/"))
+
+	var names []string
+	seen := make(map[string]bool)
+	for _, pattern := range bucketURLPatterns {
+		for _, m := range pattern.re.FindAllStringSubmatch(text, -1) {
+			name := strings.Join(m[1:], "/")
+			routes.add(name, pattern.provider)
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// extractFromPath pulls bucket names out of a file, or out of every file
+// under a directory (Burp exports, HAR files, JS bundles, ...)
+func extractFromPath(path string, routes *candidateRoutes) (names []string, files int, err error) {
+	err = filepath.WalkDir(path, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		files++
+		names = mergeNames(names, extractBucketNames(string(data), routes))
+		return nil
+	})
+	return names, files, err
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Severity levels, lowest first
+var severityLevels = []string{"info", "low", "medium", "high", "critical"}
+
+// Finding types recorded during a scan
+const (
+	findingBucketListable  = "bucket-listable"
+	findingBucketExists    = "bucket-exists"
+	findingObjectPublic    = "object-public"
+	findingVersioning      = "versioning-enabled"
+	findingObjectVersion   = "object-version-public"
+	findingObjectTorrent   = "object-torrent-public"
+	findingBucketWritable  = "bucket-writable"
+	findingBucketTagging   = "bucket-tagging-public"
+	findingBucketLifecycle = "bucket-lifecycle-public"
+	findingUploadsListable = "multipart-uploads-listable"
+
+	findingBucketAuthListable = "bucket-listable-authenticated"
+	findingBucketOwner        = "bucket-owner"
+
+	findingPartialEnumeration = "enumeration-partial"
+	findingDownloadMismatch   = "download-etag-mismatch"
+	findingSecretExposed      = "secret-exposed"
+	findingBucketContents     = "bucket-contents"
+)
+
+// Finding is a single reportable result produced while scanning
+type Finding struct {
+	Provider   string            `json:"provider,omitempty"`
+	Bucket     string            `json:"bucket"`
+	URL        string            `json:"url"`
+	Type       string            `json:"type"`
+	Severity   string            `json:"severity"`
+	Message    string            `json:"message"`
+	Evidence   string            `json:"evidence,omitempty"`
+	Key        string            `json:"key,omitempty"`
+	SHA256     string            `json:"sha256,omitempty"`
+	MD5        string            `json:"md5,omitempty"`
+	Source     string            `json:"source,omitempty"`
+	AccountID  string            `json:"account_id,omitempty"`
+	Metadata   *objectMetadata   `json:"metadata,omitempty"`
+	Encryption string            `json:"encryption,omitempty"`
+	Region     string            `json:"region,omitempty"`
+	Integrity  string            `json:"integrity,omitempty"`
+	Content    string            `json:"content,omitempty"`
+	Tags       map[string]string `json:"tags,omitempty"`
+	Stats      *bucketStats      `json:"stats,omitempty"`
+	Grade      string            `json:"grade,omitempty"`
+	Time       time.Time         `json:"time"`
+}
+
+type findingStore struct {
+	mu       sync.Mutex
+	findings []Finding
+	grades   map[string]string // by bucketKey
+	objects  map[string][]int  // object-public findings by URL, for addDownload
+}
+
+// add stores f, graded with what is known about its bucket so far
+func (s *findingStore) add(f Finding) Finding {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.grades == nil {
+		s.grades = make(map[string]string)
+	}
+	key := bucketKey(f.Provider, f.Bucket)
+	f.Grade = worseGrade(s.grades[key], f)
+	s.grades[key] = f.Grade
+	if f.Type == findingObjectPublic {
+		if s.objects == nil {
+			s.objects = make(map[string][]int)
+		}
+		s.objects[f.URL] = append(s.objects[f.URL], len(s.findings))
+	}
+	s.findings = append(s.findings, f)
+	return f
+}
+
+// addDownload records the hashes, integrity check and hash index status of a
+// downloaded object on its finding
+func (s *findingStore) addDownload(url string, info *downloadInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, i := range s.objects[url] {
+		s.findings[i].SHA256 = info.sha256
+		s.findings[i].MD5 = info.md5
+		s.findings[i].Integrity = info.integrity
+		s.findings[i].Content = info.content
+	}
+}
+
+func (s *findingStore) all() []Finding {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Finding(nil), s.findings...)
+}
+
+// evidenceSnippet trims a response body down to something that fits in a report
+func evidenceSnippet(data string) string {
+	const maxEvidence = 1024
+	data = strings.TrimSpace(data)
+	if len(data) > maxEvidence {
+		return data[:maxEvidence] + "..."
+	}
+	return data
+}
+
+// severityRank returns the position of a severity in severityLevels, or -1 if unknown
+func severityRank(severity string) int {
+	severity = strings.ToLower(strings.TrimSpace(severity))
+	for i, level := range severityLevels {
+		if level == severity {
+			return i
+		}
+	}
+	return -1
+}
+
+// recordFinding stores a finding and hands it to every configured output.
+// Findings are tagged with the provider the probe in ctx is running for.
+func recordFinding(ctx context.Context, config *Config, f Finding) {
+	if f.Time.IsZero() {
+		f.Time = time.Now().UTC()
+	}
+	if f.Provider == "" {
+		f.Provider = providerFromContext(ctx)
+	}
+	if f.Region == "" {
+		f.Region = config.locations.get(f.Provider, f.Bucket)
+	}
+	if source, ok := config.knownPublic[strings.ToLower(f.Bucket)]; ok && f.Source == "" {
+		f.Source = "probed; also listed as public in " + source
+	}
+	// Another instance working through the same --queue may have it already
+	if config.queue != nil && !config.queue.claimFinding(findingKey(f)) {
+		if config.evidence != nil {
+			config.evidence.forget(f.URL)
+		}
+		return
+	}
+	f = config.findings.add(f)
+
+	if config.notifier != nil {
+		config.notifier.notify(f)
+	}
+
+	if config.bucketReports != nil {
+		config.bucketReports.addFinding(f)
+	}
+
+	if config.evidence != nil {
+		if err := config.evidence.writeBundle(f); err != nil {
+			msg := fmt.Sprintf("Could not write evidence for %s: %v", f.Bucket, err)
+			fmt.Println(msg)
+			if config.logger != nil {
+				config.logger.Println(msg)
+			}
+		}
+	}
+}
+
+// writeJSONFile writes v as indented JSON without HTML escaping, so response
+// bodies embedded as evidence stay readable
+func writeJSONFile(filename string, v interface{}) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	enc := json.NewEncoder(file)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const (
+	gcsHost = "https://storage.googleapis.com"
+
+	findingAnonymousPermissions = "bucket-anonymous-permissions"
+)
+
+// Permissions worth asking GCS about for anonymous callers, as GCPBucketBrute does
+var gcsPermissions = []string{
+	"storage.buckets.get",
+	"storage.buckets.getIamPolicy",
+	"storage.buckets.setIamPolicy",
+	"storage.buckets.update",
+	"storage.buckets.delete",
+	"storage.objects.list",
+	"storage.objects.get",
+	"storage.objects.create",
+	"storage.objects.delete",
+}
+
+// gcsProvider probes Google Cloud Storage. Listing goes through the XML API,
+// which answers in the S3 format; existing but locked buckets are then asked
+// through the JSON API which permissions anonymous callers hold.
+type gcsProvider struct{}
+
+func newGCSProvider(config *Config) (Provider, error) {
+	return &gcsProvider{}, nil
+}
+
+func (p *gcsProvider) Name() string {
+	return "gcs"
+}
+
+func (p *gcsProvider) Probe(ctx context.Context, config *Config, bucketName string, workerId int) error {
+	data, err := getPage(ctx, config, gcsHost, bucketName)
+	if err != nil {
+		return err
+	}
+
+	if data != "" {
+		parseResults(ctx, config, data, bucketName, gcsHost, 0, workerId)
+	}
+	if config.evidence != nil {
+		config.evidence.forget(bucketURL(gcsHost, bucketName))
+	}
+
+	var gcsError S3Error
+	if err := xml.Unmarshal([]byte(data), &gcsError); err != nil || gcsError.Code == "" || gcsError.Code == "NoSuchBucket" {
+		return nil
+	}
+
+	perms, err := gcsAnonymousPermissions(ctx, config, bucketName)
+	if err != nil {
+		if config.verbose {
+			fmt.Printf("[Worker %d] Could not test permissions on %s: %v\n", workerId, bucketName, err)
+		}
+		return nil
+	}
+	if len(perms) == 0 {
+		return nil
+	}
+
+	msg := fmt.Sprintf("Anonymous permissions on %s: %s", bucketName, strings.Join(perms, ", "))
+	fmt.Println(msg)
+	if config.logger != nil {
+		config.logger.Println(msg)
+	}
+
+	recordFinding(ctx, config, Finding{
+		Bucket:   bucketName,
+		URL:      bucketURL(gcsHost, bucketName),
+		Type:     findingAnonymousPermissions,
+		Severity: gcsPermissionSeverity(perms),
+		Message:  msg,
+		Evidence: strings.Join(perms, "\n"),
+	})
+	return nil
+}
+
+// gcsAnonymousPermissions asks the testIamPermissions endpoint which of
+// gcsPermissions an unauthenticated caller holds on bucketName
+func gcsAnonymousPermissions(ctx context.Context, config *Config, bucketName string) ([]string, error) {
+	query := url.Values{}
+	for _, perm := range gcsPermissions {
+		query.Add("permissions", perm)
+	}
+	testURL := fmt.Sprintf("%s/storage/v1/b/%s/iam/testPermissions?%s", gcsHost, url.PathEscape(bucketName), query.Encode())
+
+	_, body, err := fetchURL(ctx, config, "GET", testURL)
+	if err != nil {
+		return nil, err
+	}
+	if config.evidence != nil {
+		config.evidence.forget(testURL)
+	}
+
+	var result struct {
+		Permissions []string `json:"permissions"`
+		Error       *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+	if result.Error != nil {
+		return nil, fmt.Errorf("%d: %s", result.Error.Code, result.Error.Message)
+	}
+	return result.Permissions, nil
+}
+
+func gcsPermissionSeverity(perms []string) string {
+	severity := "low"
+	for _, perm := range perms {
+		switch perm {
+		case "storage.buckets.setIamPolicy", "storage.objects.create", "storage.objects.delete",
+			"storage.buckets.update", "storage.buckets.delete":
+			return "critical"
+		case "storage.objects.list", "storage.objects.get":
+			severity = "high"
+		}
+	}
+	return severity
+}
@@ -0,0 +1,132 @@
+package main
+
+import "strings"
+
+// Generator is a permutation strategy: it builds candidate bucket names from
+// the keywords. Every generator in generators runs in keyword mode and the
+// union of their names is scanned. Package main can't be imported, so
+// strategies from outside this tree would need the scanner moved into a
+// package of its own first.
+type Generator interface {
+	// Name identifies the strategy in progress output
+	Name() string
+	// Generate adds the names it builds from keywords to perms
+	Generate(perms map[string]bool, keywords []string, rules *PermutationRules)
+}
+
+// The strategies run in keyword mode, in order
+var generators = []Generator{
+	coreGenerator{},
+	domainGenerator{},
+	yearGenerator{},
+	crossKeywordGenerator{},
+}
+
+// keywordForms normalises a keyword. Company names with spaces become
+// hyphenated ("acme corp." -> "acme-corp"), with the unseparated form
+// ("acmecorp") returned as joined; normalized is the name without its legal
+// form ("acme-corp-llc" -> "acme").
+func keywordForms(keyword string) (word, joined, normalized string) {
+	word = strings.ToLower(strings.TrimSpace(keyword))
+
+	if strings.Contains(word, " ") {
+		var parts []string
+		for _, part := range strings.Fields(word) {
+			if part = strings.Trim(part, ".,"); part != "" {
+				parts = append(parts, part)
+			}
+		}
+		word = strings.Join(parts, "-")
+		joined = strings.Join(parts, "")
+	}
+
+	return word, joined, stripCorporateSuffixes(word)
+}
+
+// coreGenerator combines each keyword with the prefixes, suffixes and
+// patterns of the rules, and adds typo variants when asked to
+type coreGenerator struct{}
+
+func (coreGenerator) Name() string {
+	return "core"
+}
+
+func (coreGenerator) Generate(perms map[string]bool, keywords []string, rules *PermutationRules) {
+	for _, keyword := range keywords {
+		word, joined, normalized := keywordForms(keyword)
+		if joined != "" {
+			addPermutation(perms, joined)
+		}
+
+		// Add the base word
+		addPermutation(perms, word)
+
+		// Permute the name without its legal form too
+		if normalized != word {
+			addPermutation(perms, normalized)
+			generateCorePermutations(perms, normalized, rules)
+		}
+
+		// Extract base name from word (for domains and complex inputs)
+		baseName := extractBaseName(word)
+		if baseName != word {
+			addPermutation(perms, baseName)
+		}
+
+		generateCorePermutations(perms, word, rules)
+
+		// Misspellings of the name itself, not of every permutation
+		if rules != nil && rules.Typos {
+			for _, name := range []string{word, baseName} {
+				for _, variant := range typoVariants(name) {
+					addPermutation(perms, variant)
+				}
+			}
+		}
+	}
+}
+
+// domainGenerator handles keywords that are domain names
+type domainGenerator struct{}
+
+func (domainGenerator) Name() string {
+	return "domain"
+}
+
+func (domainGenerator) Generate(perms map[string]bool, keywords []string, rules *PermutationRules) {
+	for _, keyword := range keywords {
+		if word, _, _ := keywordForms(keyword); strings.Contains(word, ".") {
+			generateDomainPermutations(perms, word)
+		}
+	}
+}
+
+// yearGenerator appends years, and optionally months and quarters
+type yearGenerator struct{}
+
+func (yearGenerator) Name() string {
+	return "years"
+}
+
+func (yearGenerator) Generate(perms map[string]bool, keywords []string, rules *PermutationRules) {
+	for _, keyword := range keywords {
+		word, _, normalized := keywordForms(keyword)
+		if normalized != word {
+			generateYearPermutations(perms, normalized, rules)
+		}
+		generateYearPermutations(perms, word, rules)
+	}
+}
+
+// crossKeywordGenerator combines keywords with each other
+type crossKeywordGenerator struct{}
+
+func (crossKeywordGenerator) Name() string {
+	return "cross-keyword"
+}
+
+func (crossKeywordGenerator) Generate(perms map[string]bool, keywords []string, rules *PermutationRules) {
+	if len(keywords) > 1 {
+		generateCrossKeywordPermutations(perms, keywords)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+)
+
+const githubAPIURL = "https://api.github.com"
+
+// Ten pages of 100 covers all but the very largest organisations
+const maxGitHubPages = 10
+
+type githubRepo struct {
+	Name string `json:"name"`
+}
+
+// githubRepoNames lists the public repositories of an organisation, or of a
+// user account if there is no such organisation. $GITHUB_TOKEN raises the
+// API rate limit if set.
+func githubRepoNames(config *Config, owner string) ([]string, error) {
+
+	var names []string
+	kind := "orgs"
+	for page := 1; page <= maxGitHubPages; page++ {
+		reqURL := fmt.Sprintf("%s/%s/%s/repos?type=public&per_page=100&page=%d",
+			githubAPIURL, kind, url.PathEscape(owner), page)
+		req, err := http.NewRequest("GET", reqURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", "application/vnd.github+json")
+		if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		resp, err := config.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		var repos []githubRepo
+		switch {
+		case resp.StatusCode == http.StatusNotFound && kind == "orgs" && page == 1:
+			// Not an organisation; try it as a user
+			resp.Body.Close()
+			kind = "users"
+			page--
+			continue
+		case resp.StatusCode == http.StatusForbidden && resp.Header.Get("X-RateLimit-Remaining") == "0":
+			resp.Body.Close()
+			return nil, fmt.Errorf("GitHub API rate limit reached (set GITHUB_TOKEN to raise it)")
+		case resp.StatusCode != http.StatusOK:
+			resp.Body.Close()
+			return nil, fmt.Errorf("GitHub API returned %s for %s", resp.Status, owner)
+		}
+
+		err = json.NewDecoder(resp.Body).Decode(&repos)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("parsing GitHub response: %v", err)
+		}
+
+		for _, repo := range repos {
+			names = append(names, repo.Name)
+		}
+		if len(repos) < 100 {
+			break
+		}
+	}
+	return names, nil
+}
+
+// seedFromGitHub derives candidates from an organisation's repository names.
+// Repos are tried as-is only when they carry the organisation's name, since
+// names such as "docs" or "website" are taken by someone else; otherwise
+// they are tried prefixed with it.
+func seedFromGitHub(config *Config, owner string) (*seedResult, error) {
+	repos, err := githubRepoNames(config, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	org := strings.ToLower(owner)
+	perms := make(map[string]bool)
+	for _, repo := range repos {
+		repo = strings.Trim(strings.ToLower(strings.NewReplacer("_", "-", ".", "-").Replace(repo)), "-")
+		if repo == "" || repo == "github" {
+			continue
+		}
+
+		if strings.Contains(repo, org) {
+			addPermutation(perms, repo)
+		} else {
+			addPermutation(perms, org+"-"+repo)
+			addPermutation(perms, org+repo)
+		}
+		// acme-website -> acme-website-prod, acme-website-backup
+		if stem := strings.TrimPrefix(strings.TrimPrefix(repo, org), "-"); stem != "" {
+			for _, affix := range []string{"prod", "backup"} {
+				addPermutation(perms, org+"-"+stem+"-"+affix)
+			}
+		}
+	}
+
+	result := &seedResult{keywords: []string{owner}}
+	for name := range perms {
+		if config.rules.validName(name) {
+			result.names = append(result.names, name)
+		}
+	}
+	sort.Strings(result.names)
+
+	if config.verbose {
+		fmt.Printf("Found %d public repositories for %s on GitHub\n", len(repos), owner)
+	}
+	return result, nil
+}
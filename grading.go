@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Risk grades of a bucket, lowest first
+var bucketGradeLevels = []string{"PRIVATE", "AUTH-ONLY", "READ-PUBLIC", "LIST-PUBLIC", "WRITE-PUBLIC"}
+
+// findingGrades is the grade each kind of finding shows a bucket deserves;
+// other findings only show that it exists
+var findingGrades = map[string]string{
+	findingBucketAuthListable: "AUTH-ONLY",
+	findingObjectPublic:       "READ-PUBLIC",
+	findingObjectVersion:      "READ-PUBLIC",
+	findingObjectTorrent:      "READ-PUBLIC",
+	findingBucketListable:     "LIST-PUBLIC",
+	findingKnownPublic:        "LIST-PUBLIC",
+	findingBucketWritable:     "WRITE-PUBLIC",
+}
+
+func gradeRank(grade string) int {
+	for i, level := range bucketGradeLevels {
+		if level == grade {
+			return i
+		}
+	}
+	return -1
+}
+
+// worseGrade returns the grade a bucket has once finding f is added to grade
+func worseGrade(grade string, f Finding) string {
+	g, ok := findingGrades[f.Type]
+	if !ok {
+		g = "PRIVATE"
+	}
+	if gradeRank(g) > gradeRank(grade) {
+		return g
+	}
+	return grade
+}
+
+// bucketGrades combines every finding about each bucket into its grade, by
+// bucketKey
+func bucketGrades(findings []Finding) map[string]string {
+	grades := make(map[string]string)
+	for _, f := range findings {
+		key := bucketKey(f.Provider, f.Bucket)
+		grades[key] = worseGrade(grades[key], f)
+	}
+	return grades
+}
+
+// applyGrades sets the final grade of its bucket on every finding
+func applyGrades(findings []Finding) []Finding {
+	grades := bucketGrades(findings)
+	for i := range findings {
+		findings[i].Grade = grades[bucketKey(findings[i].Provider, findings[i].Bucket)]
+	}
+	return findings
+}
+
+// printGrades lists the buckets found, most exposed first. Buckets are
+// labelled with their provider when more than one provider found any.
+func printGrades(grades map[string]string) {
+	if len(grades) == 0 {
+		return
+	}
+
+	// The same name on two providers is two buckets
+	type graded struct{ provider, bucket, grade string }
+	buckets := make([]graded, 0, len(grades))
+	providers := make(map[string]bool)
+	for key, grade := range grades {
+		provider, bucket := splitBucketKey(key)
+		buckets = append(buckets, graded{provider, bucket, grade})
+		providers[provider] = true
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		if ri, rj := gradeRank(buckets[i].grade), gradeRank(buckets[j].grade); ri != rj {
+			return ri > rj
+		}
+		if buckets[i].bucket != buckets[j].bucket {
+			return buckets[i].bucket < buckets[j].bucket
+		}
+		return buckets[i].provider < buckets[j].provider
+	})
+
+	var lines []string
+	for _, b := range buckets {
+		line := fmt.Sprintf("\t%-12s %s", b.grade, b.bucket)
+		if len(providers) > 1 {
+			line += " (" + b.provider + ")"
+		}
+		lines = append(lines, line)
+	}
+	fmt.Printf("Buckets found (%d):\n%s\n", len(buckets), strings.Join(lines, "\n"))
+}
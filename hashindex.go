@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Whether downloaded content was in the hash index before this run
+const (
+	contentNew  = "new"
+	contentSeen = "previously-seen"
+)
+
+// hashIndexEntry is one piece of content ever downloaded, by its SHA-256
+type hashIndexEntry struct {
+	SHA256    string    `json:"sha256"`
+	Size      int64     `json:"size"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	URLs      []string  `json:"urls"`
+}
+
+// hashIndex is kept across runs in the --hash-index file, so repeated scans
+// of the same buckets can tell new content from content obtained before
+type hashIndex struct {
+	mu      sync.Mutex
+	started time.Time
+	entries map[string]*hashIndexEntry
+	counts  map[string]int
+}
+
+// loadHashIndex reads the index an earlier run wrote, or starts an empty
+// one if there is none yet
+func loadHashIndex(filename string) (*hashIndex, error) {
+	x := &hashIndex{
+		started: time.Now().UTC(),
+		entries: make(map[string]*hashIndexEntry),
+		counts:  make(map[string]int),
+	}
+	data, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return x, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*hashIndexEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		x.entries[e.SHA256] = e
+	}
+	return x, nil
+}
+
+// record adds a download to the index and returns whether its content is
+// new or was already downloaded by an earlier run
+func (x *hashIndex) record(url string, info *downloadInfo) string {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	now := time.Now().UTC()
+	e, ok := x.entries[info.sha256]
+	if !ok {
+		e = &hashIndexEntry{SHA256: info.sha256, Size: info.size, FirstSeen: now}
+		x.entries[info.sha256] = e
+	}
+	e.LastSeen = now
+	known := false
+	for _, u := range e.URLs {
+		known = known || u == url
+	}
+	if !known {
+		e.URLs = append(e.URLs, url)
+	}
+
+	content := contentNew
+	if e.FirstSeen.Before(x.started) {
+		content = contentSeen
+	}
+	x.counts[content]++
+	return content
+}
+
+// summary returns how many downloads this run were new and previously seen
+func (x *hashIndex) summary() (fresh, seen int) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.counts[contentNew], x.counts[contentSeen]
+}
+
+func (x *hashIndex) write(filename string) error {
+	x.mu.Lock()
+	entries := make([]*hashIndexEntry, 0, len(x.entries))
+	for _, e := range x.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].FirstSeen.Equal(entries[j].FirstSeen) {
+			return entries[i].FirstSeen.Before(entries[j].FirstSeen)
+		}
+		return entries[i].SHA256 < entries[j].SHA256
+	})
+	err := writeJSONFile(filename, entries)
+	x.mu.Unlock()
+	return err
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+)
+
+// headerList collects the repeatable --header flag
+type headerList []string
+
+func (h *headerList) String() string {
+	return strings.Join(*h, ", ")
+}
+
+func (h *headerList) Set(value string) error {
+	name, _, ok := strings.Cut(value, ":")
+	if !ok || strings.TrimSpace(name) == "" || strings.ContainsAny(strings.TrimSpace(name), " \t") {
+		return fmt.Errorf("expected 'Name: value', got %q", value)
+	}
+	*h = append(*h, value)
+	return nil
+}
+
+// headerTransport adds the --user-agent and --header values to every
+// request, e.g. to identify the scanner to an allow-list
+type headerTransport struct {
+	base       http.RoundTripper
+	userAgent  string
+	userAgents []string // one picked at random for each request instead (--stealth)
+	headers    http.Header
+	host       string
+}
+
+func newHeaderTransport(base http.RoundTripper, userAgent string, headers headerList) *headerTransport {
+	t := &headerTransport{base: base, userAgent: userAgent, headers: make(http.Header)}
+	for _, header := range headers {
+		name, value, _ := strings.Cut(header, ":")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if strings.EqualFold(name, "Host") {
+			t.host = value
+			continue
+		}
+		t.headers.Add(name, value)
+	}
+	return t
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A RoundTripper mustn't change the request it is given
+	req = req.Clone(req.Context())
+	if len(t.userAgents) > 0 {
+		req.Header.Set("User-Agent", t.userAgents[rand.Intn(len(t.userAgents))])
+	} else if t.userAgent != "" {
+		req.Header.Set("User-Agent", t.userAgent)
+	}
+	for name, values := range t.headers {
+		req.Header[name] = values
+	}
+	if t.host != "" {
+		req.Host = t.host
+	}
+	return t.base.RoundTrip(req)
+}
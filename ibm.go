@@ -0,0 +1,27 @@
+package main
+
+import "fmt"
+
+// IBM Cloud Object Storage public endpoints: cross-region (us, eu, ap) first,
+// then the regional ones
+var ibmRegions = []string{
+	"us", "eu", "ap",
+	"us-south", "us-east", "ca-tor", "br-sao", "eu-gb", "eu-de", "eu-es", "jp-tok", "jp-osa", "au-syd",
+}
+
+func newIBMProvider(config *Config) (Provider, error) {
+	regions, err := selectRegions(config.ibmRegions, ibmRegions, "IBM COS region")
+	if err != nil {
+		return nil, err
+	}
+
+	var hosts []string
+	for _, region := range regions {
+		hosts = append(hosts, fmt.Sprintf("https://s3.%s.cloud-object-storage.appdomain.cloud", region))
+	}
+
+	return &s3Provider{
+		name:  "ibm",
+		hosts: func(string) []string { return hosts },
+	}, nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"os"
+)
+
+// existingDownload returns the local copy of job's object left by an earlier
+// run if it is still current: the same size, and the same MD5 where the ETag
+// gives one, or otherwise not older than the object. It returns nil if the
+// object needs downloading. The copy is scanned for secrets like a fresh
+// download would be.
+func existingDownload(config *Config, job downloadJob) *downloadInfo {
+	meta := job.meta
+	if meta == nil || meta.ContentLength < 0 {
+		return nil
+	}
+	_, fileName, err := downloadPath(job.fileURL, job.bucketName, job.key, job.depth)
+	if err != nil {
+		return nil
+	}
+	stat, err := os.Stat(fileName)
+	if err != nil || !stat.Mode().IsRegular() || stat.Size() != meta.ContentLength {
+		return nil
+	}
+
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	sha := sha256.New()
+	md := md5.New()
+	sinks := []io.Writer{sha, md}
+	var secrets *secretScanner
+	if config.secretRules != nil {
+		secrets = newSecretScanner(config.secretRules)
+		sinks = append(sinks, secrets)
+	}
+	if _, err := io.Copy(io.MultiWriter(sinks...), file); err != nil {
+		return nil
+	}
+
+	info := &downloadInfo{
+		path:   fileName,
+		size:   stat.Size(),
+		sha256: hex.EncodeToString(sha.Sum(nil)),
+		md5:    hex.EncodeToString(md.Sum(nil)),
+		etag:   meta.ETag,
+	}
+	info.integrity = checkIntegrity(meta.ETag, info.md5, meta)
+	if secrets != nil {
+		info.secrets = secrets.hits
+	}
+	switch info.integrity {
+	case integrityMismatch:
+		return nil
+	case "":
+		// Multipart ETags say nothing about the content; go by date instead
+		modified, err := http.ParseTime(meta.LastModified)
+		if err != nil || stat.ModTime().Before(modified) {
+			return nil
+		}
+	}
+	return info
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Integrity results of a download, as recorded on its finding
+const (
+	integrityVerified = "etag-verified"
+	integrityMismatch = "etag-mismatch"
+)
+
+// checkIntegrity compares a download's MD5 with the object's ETag. The ETag
+// is the MD5 of the content only for objects uploaded in one part without
+// SSE-KMS or SSE-C; multipart ETags end in "-<parts>" and the others can't
+// be told apart, so anything else is left unverified ("").
+func checkIntegrity(etag, md5 string, meta *objectMetadata) string {
+	etag = strings.ToLower(strings.Trim(etag, `"`))
+	if len(etag) != 32 || strings.Trim(etag, "0123456789abcdef") != "" {
+		return ""
+	}
+	if meta != nil && meta.Encryption == "aws:kms" {
+		return ""
+	}
+	if etag == md5 {
+		return integrityVerified
+	}
+	return integrityMismatch
+}
+
+// reportIntegrityMismatch records a download whose content doesn't match the
+// object's ETag: it changed or was corrupted on the way
+func reportIntegrityMismatch(ctx context.Context, config *Config, job downloadJob, info *downloadInfo, etag string) {
+	msg := fmt.Sprintf("Downloaded %s does not match its ETag (MD5 %s, ETag %s)", job.fileURL, info.md5, etag)
+	fmt.Println(msg)
+	if config.logger != nil {
+		config.logger.Println(msg)
+	}
+
+	recordFinding(ctx, config, Finding{
+		Bucket:   job.bucketName,
+		URL:      job.fileURL,
+		Type:     findingDownloadMismatch,
+		Key:      job.key,
+		Severity: "info",
+		Message:  fmt.Sprintf("Download of %s from bucket %s failed its ETag check; the copy in %s may not match the object", job.key, job.bucketName, info.path),
+		Evidence: fmt.Sprintf("MD5 %s, ETag %s", info.md5, etag),
+		SHA256:   info.sha256,
+		MD5:      info.md5,
+	})
+}
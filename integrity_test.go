@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// A download whose ETag check fails after its bucket was probed must not
+// leave the bucket's report holding only the mismatch
+func TestIntegrityMismatchKeepsBucketReport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"00000000000000000000000000000000"`)
+		w.Write([]byte("changed since it was listed"))
+	}))
+	defer srv.Close()
+	t.Chdir(t.TempDir())
+
+	reportDir := t.TempDir()
+	config := &Config{
+		scan:          context.Background(),
+		intake:        context.Background(),
+		client:        srv.Client(),
+		metrics:       &scanMetrics{},
+		findings:      &findingStore{},
+		locations:     &bucketLocations{},
+		bucketReports: newBucketReportStore(reportDir),
+	}
+	config.downloads = startDownloadPool(config, 1)
+
+	ctx := withProvider(context.Background(), "aws")
+	fileURL := objectURL(srv.URL, "acme", "a.txt")
+	config.bucketReports.setAccess("aws", "acme", bucketURL(srv.URL, "acme"), "listable")
+	config.bucketReports.addObject("aws", "acme", objectReport{Key: "a.txt", URL: fileURL, Size: 27, Access: "public"})
+	recordFinding(ctx, config, Finding{Bucket: "acme", URL: fileURL, Type: findingObjectPublic, Key: "a.txt"})
+
+	// The probe worker flushes the bucket while its download is still queued
+	config.downloads.queue(downloadJob{provider: "aws", fileURL: fileURL, bucketName: "acme", key: "a.txt", size: -1})
+	if err := config.bucketReports.flush("acme"); err != nil {
+		t.Fatal(err)
+	}
+	config.downloads.wait()
+	if err := config.bucketReports.flushAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(reportDir, "acme.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report bucketReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Access != "listable" || report.ObjectCount != 1 || len(report.Objects) != 1 {
+		t.Errorf("report lost the listing: access %q, %d object(s)", report.Access, len(report.Objects))
+	}
+	var types []string
+	for _, f := range report.Findings {
+		types = append(types, f.Type)
+	}
+	if len(types) != 2 || types[0] != findingObjectPublic || types[1] != findingDownloadMismatch {
+		t.Errorf("report findings = %v, want the public object and the mismatch", types)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// handleInterrupts returns a context that the first Ctrl-C (or SIGTERM)
+// cancels: no more candidates are handed out, in-flight requests are
+// abandoned and the results so far are still written. A second one quits
+// at once.
+func handleInterrupts(config *Config) context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		<-signals
+		msg := "Interrupted: finishing up and writing results so far (Ctrl-C again to quit now)"
+		fmt.Println(msg)
+		if config.logger != nil {
+			config.logger.Println(msg)
+		}
+		cancel()
+
+		<-signals
+		fmt.Println("Quitting without writing results")
+		os.Exit(130)
+	}()
+	return ctx
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// candidateResult records that a candidate was probed, for reports that
+// cover every candidate rather than only the findings
+type candidateResult struct {
+	bucket   string
+	duration time.Duration
+	err      string
+}
+
+type candidateLog struct {
+	mu      sync.Mutex
+	results []candidateResult
+}
+
+func (l *candidateLog) add(r candidateResult) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.results = append(l.results, r)
+}
+
+func (l *candidateLog) all() []candidateResult {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]candidateResult(nil), l.results...)
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Error     *junitFailure `xml:"error,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// writeJUnitReport writes one test case per candidate bucket. A case fails
+// when the bucket has a finding at or above failOn, and errors when it could
+// not be probed.
+func writeJUnitReport(filename string, started time.Time, candidates []candidateResult, findings []Finding, failOn string) error {
+	byBucket := make(map[string][]Finding)
+	for _, f := range findings {
+		byBucket[f.Bucket] = append(byBucket[f.Bucket], f)
+	}
+
+	// Buckets reported without being probed (e.g. known-public) still get a case
+	probed := make(map[string]bool)
+	for _, c := range candidates {
+		probed[c.bucket] = true
+	}
+	for bucket := range byBucket {
+		if !probed[bucket] {
+			candidates = append(candidates, candidateResult{bucket: bucket})
+			probed[bucket] = true
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].bucket < candidates[j].bucket })
+
+	elapsed := fmt.Sprintf("%.3f", time.Since(started).Seconds())
+	suite := junitTestSuite{
+		Name:      "bucket_finder",
+		Time:      elapsed,
+		Timestamp: started.UTC().Format("2006-01-02T15:04:05"),
+	}
+	threshold := severityRank(failOn)
+
+	for _, c := range candidates {
+		tc := junitTestCase{
+			ClassName: "bucket_finder.exposure",
+			Name:      c.bucket,
+			Time:      fmt.Sprintf("%.3f", c.duration.Seconds()),
+		}
+
+		var failing, other []string
+		worst, grade := "", ""
+		for _, f := range byBucket[c.bucket] {
+			grade = worseGrade(grade, f)
+			line := fmt.Sprintf("[%s] %s: %s (%s)", f.Severity, f.Type, f.Message, f.URL)
+			if severityRank(f.Severity) >= threshold {
+				failing = append(failing, line)
+				if worst == "" || severityRank(f.Severity) > severityRank(worst) {
+					worst = f.Severity
+				}
+			} else {
+				other = append(other, line)
+			}
+		}
+
+		switch {
+		case len(failing) > 0:
+			tc.Failure = &junitFailure{
+				Message: fmt.Sprintf("bucket %s is publicly exposed, graded %s (%d %s or worse findings)", c.bucket, grade, len(failing), failOn),
+				Type:    worst,
+				Body:    strings.Join(failing, "\n"),
+			}
+			suite.Failures++
+		case c.err != "":
+			tc.Error = &junitFailure{
+				Message: "probe failed",
+				Type:    "error",
+				Body:    c.err,
+			}
+			suite.Errors++
+		}
+		if len(other) > 0 {
+			tc.SystemOut = strings.Join(other, "\n")
+		}
+
+		suite.Cases = append(suite.Cases, tc)
+	}
+	suite.Tests = len(suite.Cases)
+
+	report := junitTestSuites{
+		Name:     "bucket_finder",
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Errors:   suite.Errors,
+		Time:     elapsed,
+		Suites:   []junitTestSuite{suite},
+	}
+
+	data, err := xml.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, append([]byte(xml.Header), data...), 0644)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// keyFilter picks the object keys worth reporting and downloading
+type keyFilter struct {
+	pattern    *regexp.Regexp
+	extensions []string
+}
+
+// newKeyFilter builds a filter from --key-filter and --key-ext, or returns
+// nil if neither is set
+func newKeyFilter(pattern, extensions string) (*keyFilter, error) {
+	if pattern == "" && extensions == "" {
+		return nil, nil
+	}
+
+	f := &keyFilter{}
+	if pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("bad key filter: %v", err)
+		}
+		f.pattern = re
+	}
+	for _, ext := range strings.Split(extensions, ",") {
+		if ext = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), ".")); ext != "" {
+			f.extensions = append(f.extensions, ext)
+		}
+	}
+	return f, nil
+}
+
+// match reports whether key passes the filter: it must match the pattern
+// and have one of the extensions, where given. Dotfiles such as .env count
+// as having their name as extension.
+func (f *keyFilter) match(key string) bool {
+	if f == nil {
+		return true
+	}
+	if f.pattern != nil && !f.pattern.MatchString(key) {
+		return false
+	}
+	if len(f.extensions) == 0 {
+		return true
+	}
+	ext := strings.ToLower(strings.TrimPrefix(path.Ext(key), "."))
+	return containsString(f.extensions, ext)
+}
@@ -0,0 +1,72 @@
+package main
+
+import "strings"
+
+// Legal-form words that trail company names but never appear in their buckets
+var corporateSuffixes = []string{
+	"inc", "incorporated", "llc", "llp", "lp", "ltd", "limited", "corp", "corporation", "co",
+	"company", "plc", "gmbh", "ag", "kg", "sa", "sas", "sarl", "srl", "spa", "bv", "nv", "ab",
+	"as", "oy", "pty", "pvt", "kk",
+}
+
+// isCorporateSuffix reports whether word is a legal form such as "Inc." or "GmbH"
+func isCorporateSuffix(word string) bool {
+	word = strings.ToLower(strings.Trim(word, ".,"))
+	word = strings.ReplaceAll(word, ".", "")
+	return containsString(corporateSuffixes, word)
+}
+
+// stripCorporateSuffixes removes trailing legal forms from a space or hyphen
+// separated company name, so "acme corp llc" becomes "acme"
+func stripCorporateSuffixes(name string) string {
+	words := strings.FieldsFunc(name, func(r rune) bool { return r == ' ' || r == '-' })
+	for len(words) > 1 && isCorporateSuffix(words[len(words)-1]) {
+		words = words[:len(words)-1]
+	}
+	return strings.Join(words, "-")
+}
+
+// attachCorporateSuffixes joins legal forms split off by parseKeywords back
+// onto the name before them, so "Acme Corp LLC" stays one keyword
+func attachCorporateSuffixes(keywords []string) []string {
+	var joined []string
+	for _, keyword := range keywords {
+		if len(joined) > 0 && isCorporateSuffix(keyword) {
+			joined[len(joined)-1] += " " + keyword
+			continue
+		}
+		joined = append(joined, keyword)
+	}
+	return joined
+}
+
+// Look-alike characters swapped by typosquatters and careless typists
+var leetSwaps = map[rune]rune{'o': '0', '0': 'o', 'l': '1', '1': 'l', 'i': '1'}
+
+// typoVariants returns misspellings of word: each letter dropped, each
+// letter doubled, and each look-alike character swapped (0/o, 1/l)
+func typoVariants(word string) []string {
+	runes := []rune(word)
+	var variants []string
+
+	for i, r := range runes {
+		if r == '-' || r == '.' {
+			continue
+		}
+
+		// Missing letter
+		variants = append(variants, string(runes[:i])+string(runes[i+1:]))
+
+		// Doubled letter
+		variants = append(variants, string(runes[:i+1])+string(runes[i:]))
+
+		// Look-alike substitution
+		if swap, ok := leetSwaps[r]; ok {
+			swapped := append([]rune(nil), runes...)
+			swapped[i] = swap
+			variants = append(variants, string(swapped))
+		}
+	}
+
+	return variants
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const findingKnownPublic = "bucket-known-public"
+
+// loadKnownPublic reads one or more exports of already-known open buckets
+// (GrayhatWarfare CSV, or any CSV/text file with a bucket column) and maps
+// each bucket name to the file it came from
+func loadKnownPublic(filenames string) (map[string]string, error) {
+	known := make(map[string]string)
+
+	for _, filename := range strings.Split(filenames, ",") {
+		filename = strings.TrimSpace(filename)
+		if filename == "" {
+			continue
+		}
+
+		names, err := readKnownPublicFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", filename, err)
+		}
+
+		source := filepath.Base(filename)
+		for _, name := range names {
+			if _, ok := known[name]; !ok {
+				known[name] = source
+			}
+		}
+	}
+
+	return known, nil
+}
+
+func readKnownPublicFile(filename string) ([]string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	var names []string
+	column := 0
+	first := true
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// Pick the bucket column out of a header row if there is one
+		if first {
+			first = false
+			if col := knownPublicColumn(record); col >= 0 {
+				column = col
+				continue
+			}
+		}
+
+		if column >= len(record) {
+			continue
+		}
+		if name := bucketFromKnownValue(record[column]); name != "" {
+			names = append(names, name)
+		}
+	}
+
+	return names, nil
+}
+
+func knownPublicColumn(header []string) int {
+	for _, want := range []string{"bucket", "bucket_name", "bucketname", "name", "url"} {
+		for i, col := range header {
+			if strings.EqualFold(strings.TrimSpace(col), want) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// bucketFromKnownValue accepts a bare bucket name or an S3 URL of either style
+func bucketFromKnownValue(value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "" {
+		return ""
+	}
+
+	if strings.Contains(value, "://") {
+		u, err := url.Parse(value)
+		if err != nil {
+			return ""
+		}
+		host := u.Hostname()
+		if i := strings.Index(host, ".s3"); i > 0 {
+			return host[:i]
+		}
+		return strings.SplitN(strings.TrimPrefix(u.Path, "/"), "/", 2)[0]
+	}
+
+	if i := strings.Index(value, ".s3"); i > 0 && strings.HasSuffix(value, ".amazonaws.com") {
+		return value[:i]
+	}
+	return value
+}
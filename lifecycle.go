@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type LifecycleConfiguration struct {
+	XMLName xml.Name        `xml:"LifecycleConfiguration"`
+	Rules   []LifecycleRule `xml:"Rule"`
+}
+
+type LifecycleRule struct {
+	ID     string `xml:"ID"`
+	Status string `xml:"Status"`
+	// Older rules give the prefix directly, newer ones in a filter
+	Prefix       string `xml:"Prefix"`
+	FilterPrefix string `xml:"Filter>Prefix"`
+	Transitions  []struct {
+		Days         int    `xml:"Days"`
+		StorageClass string `xml:"StorageClass"`
+	} `xml:"Transition"`
+	ExpirationDays           int `xml:"Expiration>Days"`
+	NoncurrentExpirationDays int `xml:"NoncurrentVersionExpiration>NoncurrentDays"`
+}
+
+// String sums up a rule, e.g. "archive (logs/): GLACIER after 30d, expire after 365d"
+func (r LifecycleRule) String() string {
+	name := r.ID
+	if name == "" {
+		name = "rule"
+	}
+	if prefix := r.Prefix + r.FilterPrefix; prefix != "" {
+		name += " (" + prefix + ")"
+	}
+	if r.Status != "" && r.Status != "Enabled" {
+		name += " [" + strings.ToLower(r.Status) + "]"
+	}
+
+	var actions []string
+	for _, t := range r.Transitions {
+		actions = append(actions, fmt.Sprintf("%s after %dd", t.StorageClass, t.Days))
+	}
+	if r.ExpirationDays > 0 {
+		actions = append(actions, fmt.Sprintf("expire after %dd", r.ExpirationDays))
+	}
+	if r.NoncurrentExpirationDays > 0 {
+		actions = append(actions, fmt.Sprintf("expire old versions after %dd", r.NoncurrentExpirationDays))
+	}
+	if len(actions) == 0 {
+		return name
+	}
+	return name + ": " + strings.Join(actions, ", ")
+}
+
+// checkLifecycle reads a found bucket's lifecycle rules, which show what is
+// archived or deleted when and, through rule names and prefixes, what the
+// bucket holds
+func checkLifecycle(ctx context.Context, config *Config, bucketName, host string, depth, workerId int) {
+	tabs := strings.Repeat("\t", depth+1)
+	workerPrefix := ""
+	if config.verbose {
+		workerPrefix = fmt.Sprintf("[Worker %d] ", workerId)
+	}
+	lifecycleURL := bucketURL(host, bucketName) + "?lifecycle"
+
+	resp, body, err := fetchURL(ctx, config, "GET", lifecycleURL)
+	var lifecycle LifecycleConfiguration
+	if err != nil || resp.StatusCode != http.StatusOK || xml.Unmarshal(body, &lifecycle) != nil || len(lifecycle.Rules) == 0 {
+		if config.evidence != nil {
+			config.evidence.forget(lifecycleURL)
+		}
+		return
+	}
+
+	var rules []string
+	for _, rule := range lifecycle.Rules {
+		rules = append(rules, rule.String())
+	}
+
+	msg := fmt.Sprintf("%s%sLifecycle rules of %s are public:", workerPrefix, tabs, bucketName)
+	for _, rule := range rules {
+		msg += fmt.Sprintf("\n%s%s\t%s", workerPrefix, tabs, rule)
+	}
+	fmt.Println(msg)
+	if config.logger != nil {
+		config.logger.Println(msg)
+	}
+
+	recordFinding(ctx, config, Finding{
+		Bucket:   bucketName,
+		URL:      lifecycleURL,
+		Type:     findingBucketLifecycle,
+		Severity: "low",
+		Message:  fmt.Sprintf("Bucket %s exposes %d lifecycle rules: %s", bucketName, len(rules), strings.Join(rules, "; ")),
+		Evidence: evidenceSnippet(string(body)),
+	})
+}
@@ -0,0 +1,28 @@
+package main
+
+import "fmt"
+
+// Linode (Akamai) Object Storage clusters, each with an S3-compatible endpoint
+var linodeClusters = []string{
+	"us-east-1", "us-southeast-1", "us-ord-1", "us-iad-1", "us-lax-1", "us-mia-1", "us-sea-1",
+	"eu-central-1", "de-fra-2", "fr-par-1", "gb-lon-1", "it-mil-1", "nl-ams-1", "se-sto-1", "es-mad-1",
+	"ap-south-1", "sg-sin-2", "in-maa-1", "in-bom-2", "id-cgk-1", "jp-osa-1", "jp-tyo-3", "au-mel-1",
+	"br-gru-1",
+}
+
+func newLinodeProvider(config *Config) (Provider, error) {
+	clusters, err := selectRegions(config.linodeClusters, linodeClusters, "Linode cluster")
+	if err != nil {
+		return nil, err
+	}
+
+	var hosts []string
+	for _, cluster := range clusters {
+		hosts = append(hosts, fmt.Sprintf("https://%s.linodeobjects.com", cluster))
+	}
+
+	return &s3Provider{
+		name:  "linode",
+		hosts: func(string) []string { return hosts },
+	}, nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Keys enumerated per bucket unless --max-keys says otherwise
+const defaultMaxKeys = 10000
+
+// listQuery starts a ListObjectsV2 query, limited to --prefix and split at
+// --delimiter where set
+func listQuery(config *Config) url.Values {
+	query := url.Values{"list-type": {"2"}}
+	if config.delimiter != "" {
+		query.Set("delimiter", config.delimiter)
+	}
+	if config.prefix != "" {
+		query.Set("prefix", config.prefix)
+	}
+	return query
+}
+
+// nextListPage fetches the page of a bucket listing that follows page with
+// ListObjectsV2. The first page comes from a plain GET, so it is continued
+// after its last key; later pages carry a continuation token.
+func nextListPage(ctx context.Context, config *Config, host, bucketName string, page ListBucketResult) (ListBucketResult, error) {
+	if page.nextPage != nil {
+		return page.nextPage(ctx)
+	}
+	query := listQuery(config)
+	switch {
+	case page.NextContinuationToken != "":
+		query.Set("continuation-token", page.NextContinuationToken)
+	case page.NextMarker != "":
+		query.Set("start-after", page.NextMarker)
+	case len(page.Contents) > 0:
+		query.Set("start-after", page.Contents[len(page.Contents)-1].Key)
+	default:
+		return ListBucketResult{}, fmt.Errorf("truncated listing gives no way to continue")
+	}
+
+	pageURL := bucketURL(host, bucketName) + "?" + query.Encode()
+	_, body, err := fetchURL(ctx, config, "GET", pageURL)
+	if config.evidence != nil {
+		config.evidence.forget(pageURL)
+	}
+	if err != nil {
+		return ListBucketResult{}, err
+	}
+
+	var next ListBucketResult
+	if err := xml.Unmarshal(body, &next); err != nil || next.Name == "" {
+		return ListBucketResult{}, fmt.Errorf("unexpected listing response: %s", evidenceSnippet(string(body)))
+	}
+	return next, nil
+}
+
+// listFolder fetches the first page of a listing for --prefix and --delimiter
+func listFolder(ctx context.Context, config *Config, host, bucketName string) (ListBucketResult, error) {
+	query := listQuery(config)
+
+	folderURL := bucketURL(host, bucketName) + "?" + query.Encode()
+	_, body, err := fetchURL(ctx, config, "GET", folderURL)
+	if config.evidence != nil {
+		config.evidence.forget(folderURL)
+	}
+	if err != nil {
+		return ListBucketResult{}, err
+	}
+
+	var folder ListBucketResult
+	if err := xml.Unmarshal(body, &folder); err != nil || folder.Name == "" {
+		return ListBucketResult{}, fmt.Errorf("unexpected listing response: %s", evidenceSnippet(string(body)))
+	}
+	return folder, nil
+}
+
+// reportKeyCapReached flags a bucket with more objects than --max-keys allows enumerating
+func reportKeyCapReached(ctx context.Context, config *Config, bucketName, host string, depth, workerId int) {
+	tabs := strings.Repeat("\t", depth+1)
+	workerPrefix := ""
+	if config.verbose {
+		workerPrefix = fmt.Sprintf("[Worker %d] ", workerId)
+	}
+
+	msg := fmt.Sprintf("%s%sStopped listing %s after %d objects (--max-keys)", workerPrefix, tabs, bucketName, config.maxKeys)
+	fmt.Println(msg)
+	if config.logger != nil {
+		config.logger.Println(msg)
+	}
+
+	recordFinding(ctx, config, Finding{
+		Bucket:   bucketName,
+		URL:      bucketURL(host, bucketName),
+		Type:     findingPartialEnumeration,
+		Severity: "info",
+		Message:  fmt.Sprintf("Bucket %s holds more than %d objects; only the first %d were checked", bucketName, config.maxKeys, config.maxKeys),
+	})
+	if config.bucketReports != nil {
+		config.bucketReports.setPartial(providerFromContext(ctx), bucketName)
+	}
+}
+
+// reportListingTruncated flags a bucket whose listing says it continues
+// (IsTruncated) on a page that could not be fetched, so only listed objects
+// were seen
+func reportListingTruncated(ctx context.Context, config *Config, bucketName, host string, page ListBucketResult, listed int, err error, depth, workerId int) {
+	tabs := strings.Repeat("\t", depth+1)
+	workerPrefix := ""
+	if config.verbose {
+		workerPrefix = fmt.Sprintf("[Worker %d] ", workerId)
+	}
+
+	msg := fmt.Sprintf("%s%sListing of %s is TRUNCATED after %d objects; could not fetch the next page: %v", workerPrefix, tabs, bucketName, listed, err)
+	fmt.Println(msg)
+	if config.logger != nil {
+		config.logger.Println(msg)
+	}
+
+	pageSize := ""
+	if page.MaxKeys > 0 {
+		pageSize = fmt.Sprintf(" in pages of %d", page.MaxKeys)
+	}
+	recordFinding(ctx, config, Finding{
+		Bucket:   bucketName,
+		URL:      bucketURL(host, bucketName),
+		Type:     findingPartialEnumeration,
+		Severity: "info",
+		Message:  fmt.Sprintf("Bucket %s holds more than the %d objects listed%s; the rest of the listing could not be fetched", bucketName, listed, pageSize),
+		Evidence: err.Error(),
+	})
+	if config.bucketReports != nil {
+		config.bucketReports.setPartial(providerFromContext(ctx), bucketName)
+	}
+}
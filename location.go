@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+type LocationConstraint struct {
+	XMLName xml.Name `xml:"LocationConstraint"`
+	Region  string   `xml:",chardata"`
+}
+
+// bucketLocations remembers the region of each bucket found, so every
+// finding about the bucket can carry it
+type bucketLocations struct {
+	mu      sync.Mutex
+	regions map[string]string // by bucketKey
+}
+
+func (l *bucketLocations) get(provider, bucket string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.regions[bucketKey(provider, bucket)]
+}
+
+func (l *bucketLocations) set(provider, bucket, region string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.regions == nil {
+		l.regions = make(map[string]string)
+	}
+	l.regions[bucketKey(provider, bucket)] = region
+}
+
+// locateBucket finds the region an AWS bucket lives in, whichever endpoint
+// answered, and remembers it. GetBucketLocation is tried first; buckets
+// that deny it still name their region in the x-amz-bucket-region header
+// of a HEAD request.
+func locateBucket(ctx context.Context, config *Config, bucketName, host string) string {
+	if providerFromContext(ctx) != "aws" {
+		return ""
+	}
+	if region := config.locations.get("aws", bucketName); region != "" {
+		return region
+	}
+	base := bucketURL(host, bucketName)
+
+	region := ""
+	resp, body, err := fetchURL(ctx, config, "GET", base+"?location")
+	if config.evidence != nil {
+		config.evidence.forget(base + "?location")
+	}
+	var location LocationConstraint
+	if err == nil && resp.StatusCode == http.StatusOK && xml.Unmarshal(body, &location) == nil {
+		region = normalizeLocation(location.Region)
+	} else if req, err := http.NewRequestWithContext(ctx, "HEAD", base, nil); err == nil {
+		if resp, _, err := doRequest(config, req); err == nil {
+			region = resp.Header.Get("x-amz-bucket-region")
+		}
+	}
+
+	if region != "" {
+		config.locations.set("aws", bucketName, region)
+	}
+	return region
+}
+
+// normalizeLocation turns a LocationConstraint into a region name: us-east-1
+// has an empty constraint, and the oldest EU buckets say "EU"
+func normalizeLocation(constraint string) string {
+	switch constraint = strings.TrimSpace(constraint); constraint {
+	case "":
+		return "us-east-1"
+	case "EU":
+		return "eu-west-1"
+	}
+	return constraint
+}
+
+// regionLabel is appended to a found bucket's output line
+func regionLabel(region string) string {
+	if region == "" {
+		return ""
+	}
+	return " [" + region + "]"
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// manifestEntry records one downloaded object for the evidence chain
+type manifestEntry struct {
+	URL          string    `json:"url"`
+	Bucket       string    `json:"bucket"`
+	Key          string    `json:"key"`
+	Path         string    `json:"path"`
+	Size         int64     `json:"size"`
+	ETag         string    `json:"etag,omitempty"`
+	SHA256       string    `json:"sha256"`
+	MD5          string    `json:"md5"`
+	Integrity    string    `json:"integrity,omitempty"`
+	Content      string    `json:"content,omitempty"` // new or previously-seen, with --hash-index
+	Status       string    `json:"status"`            // downloaded, mirrored, unchanged since an earlier run, or duplicate of another object's path
+	DownloadedAt time.Time `json:"downloaded_at"`
+}
+
+// downloadManifest collects the --manifest entries as downloads finish
+type downloadManifest struct {
+	mu      sync.Mutex
+	entries []manifestEntry
+}
+
+func (m *downloadManifest) add(job downloadJob, info *downloadInfo, status string) {
+	// Mirrored objects are located by their s3:// URL instead
+	path := info.path
+	if abs, err := filepath.Abs(path); err == nil && !strings.Contains(path, "://") {
+		path = abs
+	}
+	// An unchanged file was downloaded when it was last written
+	downloadedAt := time.Now().UTC()
+	if stat, err := os.Stat(path); err == nil && status == "unchanged" {
+		downloadedAt = stat.ModTime().UTC()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries = append(m.entries, manifestEntry{
+		URL:          job.fileURL,
+		Bucket:       job.bucketName,
+		Key:          job.key,
+		Path:         path,
+		Size:         info.size,
+		ETag:         strings.Trim(info.etag, `"`),
+		SHA256:       info.sha256,
+		MD5:          info.md5,
+		Integrity:    info.integrity,
+		Content:      info.content,
+		Status:       status,
+		DownloadedAt: downloadedAt,
+	})
+}
+
+// write saves the manifest, as CSV if filename ends in .csv and JSON otherwise
+func (m *downloadManifest) write(filename string) (int, error) {
+	m.mu.Lock()
+	entries := append([]manifestEntry{}, m.entries...)
+	m.mu.Unlock()
+	sort.Slice(entries, func(i, j int) bool { return entries[i].URL < entries[j].URL })
+
+	if !strings.EqualFold(filepath.Ext(filename), ".csv") {
+		return len(entries), writeJSONFile(filename, entries)
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	w := csv.NewWriter(file)
+	w.Write([]string{"url", "bucket", "key", "path", "size", "etag", "sha256", "md5", "integrity", "content", "status", "downloaded_at"})
+	for _, e := range entries {
+		w.Write([]string{e.URL, e.Bucket, e.Key, e.Path, strconv.FormatInt(e.Size, 10), e.ETag, e.SHA256, e.MD5,
+			e.Integrity, e.Content, e.Status, e.DownloadedAt.Format(time.RFC3339)})
+	}
+	w.Flush()
+	return len(entries), w.Error()
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// objectMetadata is what a HEAD request tells about an object
+type objectMetadata struct {
+	ContentType   string `json:"content_type,omitempty"`
+	ContentLength int64  `json:"content_length"` // -1 if not given
+	ETag          string `json:"etag,omitempty"`
+	LastModified  string `json:"last_modified,omitempty"`
+	Encryption    string `json:"server_side_encryption,omitempty"`
+	KMSKeyID      string `json:"kms_key_id,omitempty"`
+}
+
+// headObject requests an object's headers, returning whether it is readable
+// and its metadata if so
+func headObject(ctx context.Context, config *Config, fileURL string) (bool, *objectMetadata) {
+	req, err := http.NewRequestWithContext(ctx, "HEAD", fileURL, nil)
+	if err != nil {
+		return false, nil
+	}
+
+	resp, err := doWithRetries(config, config.client, req)
+	if err != nil {
+		return false, nil
+	}
+	defer resp.Body.Close()
+
+	if config.evidence != nil {
+		config.evidence.recordExchange(fileURL, resp, nil)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, nil
+	}
+	return true, metadataFromResponse(resp)
+}
+
+// metadataFromResponse reads the metadata headers of a HEAD or GET response
+func metadataFromResponse(resp *http.Response) *objectMetadata {
+	return &objectMetadata{
+		ContentType:   resp.Header.Get("Content-Type"),
+		ContentLength: resp.ContentLength,
+		ETag:          strings.Trim(resp.Header.Get("ETag"), `"`),
+		LastModified:  resp.Header.Get("Last-Modified"),
+		Encryption:    resp.Header.Get("x-amz-server-side-encryption"),
+		KMSKeyID:      resp.Header.Get("x-amz-server-side-encryption-aws-kms-key-id"),
+	}
+}
+
+// sseStatus is the server-side encryption of an object, or "none" if it has
+// none. Only AWS reports SSE on every encrypted object, so elsewhere the
+// status is unknown and left empty.
+func sseStatus(ctx context.Context, meta *objectMetadata) string {
+	if meta == nil || providerFromContext(ctx) != "aws" {
+		return ""
+	}
+	if meta.Encryption == "" {
+		return "none"
+	}
+	return meta.Encryption
+}
+
+// String sums up the metadata for the object's output line
+func (m *objectMetadata) String() string {
+	var parts []string
+	if m.ContentLength >= 0 {
+		parts = append(parts, fmt.Sprintf("%d bytes", m.ContentLength))
+	}
+	if m.ContentType != "" {
+		parts = append(parts, m.ContentType)
+	}
+	if m.LastModified != "" {
+		parts = append(parts, m.LastModified)
+	}
+	if m.Encryption != "" {
+		parts = append(parts, "SSE "+m.Encryption)
+	}
+	return strings.Join(parts, ", ")
+}
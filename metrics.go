@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// scanMetrics counts the scan's progress for --metrics-listen
+type scanMetrics struct {
+	queued    atomic.Int64 // candidates handed to the probe workers
+	probed    atomic.Int64
+	failed    atomic.Int64 // probes that ended in an error
+	throttled atomic.Int64 // probes deferred because the service was throttling
+
+	mu              sync.Mutex
+	downloads       map[string]int64 // by manifest status: downloaded, mirrored, unchanged, duplicate
+	downloadedBytes int64
+}
+
+func (m *scanMetrics) addDownload(status string, size int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.downloads == nil {
+		m.downloads = make(map[string]int64)
+	}
+	m.downloads[status]++
+	if status == "downloaded" || status == "mirrored" {
+		m.downloadedBytes += size
+	}
+}
+
+// serveMetrics starts serving Prometheus metrics on addr at /metrics, for
+// as long as the process runs
+func serveMetrics(config *Config, addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		writeMetrics(w, config)
+	})
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go server.Serve(listener)
+	return nil
+}
+
+// writeMetrics writes the metrics in the Prometheus text exposition format
+func writeMetrics(out io.Writer, config *Config) {
+	w := bufio.NewWriter(out)
+	defer w.Flush()
+	m := config.metrics
+
+	metric(w, "bucketfinder_scan_start_time_seconds", "gauge", "When the scan started, in seconds since the epoch.")
+	fmt.Fprintf(w, "bucketfinder_scan_start_time_seconds %d\n", config.started.Unix())
+	metric(w, "bucketfinder_scan_running", "gauge", "1 while new candidates are still being probed, 0 once the scan is winding down.")
+	running := 1
+	if config.intake.Err() != nil {
+		running = 0
+	}
+	fmt.Fprintf(w, "bucketfinder_scan_running %d\n", running)
+
+	metric(w, "bucketfinder_candidates_queued_total", "counter", "Candidate names handed to the probe workers.")
+	fmt.Fprintf(w, "bucketfinder_candidates_queued_total %d\n", m.queued.Load())
+	metric(w, "bucketfinder_candidates_probed_total", "counter", "Candidate names probed.")
+	fmt.Fprintf(w, "bucketfinder_candidates_probed_total %d\n", m.probed.Load())
+	metric(w, "bucketfinder_probe_errors_total", "counter", "Probes that failed with an error.")
+	fmt.Fprintf(w, "bucketfinder_probe_errors_total %d\n", m.failed.Load())
+	metric(w, "bucketfinder_probes_throttled_total", "counter", "Probes deferred because the service was throttling requests.")
+	fmt.Fprintf(w, "bucketfinder_probes_throttled_total %d\n", m.throttled.Load())
+	metric(w, "bucketfinder_probe_delay_seconds", "gauge", "Current delay between a worker's probes, raised while throttled.")
+	fmt.Fprintf(w, "bucketfinder_probe_delay_seconds %g\n", config.pacing.current().Seconds())
+
+	// Findings by type and severity
+	counts := make(map[[2]string]int)
+	buckets := make(map[string]bool)
+	for _, f := range config.findings.all() {
+		counts[[2]string{f.Type, f.Severity}]++
+		buckets[bucketKey(f.Provider, f.Bucket)] = true
+	}
+	keys := make([][2]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+	metric(w, "bucketfinder_findings", "gauge", "Findings so far, by type and severity.")
+	for _, key := range keys {
+		fmt.Fprintf(w, "bucketfinder_findings{type=%q,severity=%q} %d\n", labelValue(key[0]), labelValue(key[1]), counts[key])
+	}
+	metric(w, "bucketfinder_buckets_found", "gauge", "Buckets with at least one finding.")
+	fmt.Fprintf(w, "bucketfinder_buckets_found %d\n", len(buckets))
+
+	m.mu.Lock()
+	metric(w, "bucketfinder_downloads_total", "counter", "Objects saved, by status (downloaded, mirrored, unchanged, duplicate).")
+	for _, status := range sortedKeys(m.downloads) {
+		fmt.Fprintf(w, "bucketfinder_downloads_total{status=%q} %d\n", labelValue(status), m.downloads[status])
+	}
+	metric(w, "bucketfinder_downloaded_bytes_total", "counter", "Bytes downloaded or mirrored.")
+	fmt.Fprintf(w, "bucketfinder_downloaded_bytes_total %d\n", m.downloadedBytes)
+	m.mu.Unlock()
+
+	if config.stats != nil {
+		config.stats.writeMetrics(w)
+	}
+}
+
+// writeMetrics adds the per-endpoint request counters and latency quantiles
+func (s *requestStats) writeMetrics(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hosts := make([]string, 0, len(s.endpoints))
+	for host := range s.endpoints {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	metric(w, "bucketfinder_requests_total", "counter", "HTTP requests answered, by endpoint and status code.")
+	for _, host := range hosts {
+		e := s.endpoints[host]
+		codes := make([]int, 0, len(e.statuses))
+		for code := range e.statuses {
+			codes = append(codes, code)
+		}
+		sort.Ints(codes)
+		for _, code := range codes {
+			fmt.Fprintf(w, "bucketfinder_requests_total{endpoint=%q,code=\"%d\"} %d\n", labelValue(host), code, e.statuses[code])
+		}
+	}
+	metric(w, "bucketfinder_request_errors_total", "counter", "HTTP requests that failed without a response, by endpoint and category.")
+	for _, host := range hosts {
+		e := s.endpoints[host]
+		for _, category := range sortedKeys(e.errors) {
+			fmt.Fprintf(w, "bucketfinder_request_errors_total{endpoint=%q,category=%q} %d\n", labelValue(host), category, e.errors[category])
+		}
+	}
+	metric(w, "bucketfinder_request_duration_seconds", "summary", "Time to response headers, by endpoint (quantiles over a sample).")
+	for _, host := range hosts {
+		e := s.endpoints[host]
+		sorted := append([]time.Duration{}, e.samples...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		for _, q := range []float64{0.5, 0.9, 0.99} {
+			fmt.Fprintf(w, "bucketfinder_request_duration_seconds{endpoint=%q,quantile=\"%g\"} %g\n", labelValue(host), q, percentile(sorted, q*100).Seconds())
+		}
+		fmt.Fprintf(w, "bucketfinder_request_duration_seconds_sum{endpoint=%q} %g\n", labelValue(host), e.total.Seconds())
+		fmt.Fprintf(w, "bucketfinder_request_duration_seconds_count{endpoint=%q} %d\n", labelValue(host), e.requests)
+	}
+}
+
+func metric(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// labelValue keeps a label value to characters %q writes the way the
+// exposition format expects
+func labelValue(value string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7e {
+			return '_'
+		}
+		return r
+	}, value)
+}
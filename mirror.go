@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// A single PUT can't store more than this; larger objects would need a
+// multipart upload
+const maxMirrorObject = 5 << 30
+
+// mirrorStore is an S3-compatible bucket that downloads are streamed into
+// instead of being written to disk
+type mirrorStore struct {
+	endpoint *url.URL // scheme and host
+	bucket   string
+	prefix   string
+	region   string
+	creds    *awsCredentials
+}
+
+// newMirrorStore parses the --mirror target: s3://bucket/prefix for AWS, or
+// the path-style URL of a bucket on any S3-compatible server, such as
+// http://minio.internal:9000/evidence/scans
+func newMirrorStore(target, region string, creds *awsCredentials) (*mirrorStore, error) {
+	if strings.HasPrefix(target, "s3://") {
+		target = fmt.Sprintf("https://s3.%s.amazonaws.com/%s", region, strings.TrimPrefix(target, "s3://"))
+	}
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("expected s3://bucket/prefix or http(s)://host/bucket/prefix, got %q", target)
+	}
+	bucket, prefix, _ := strings.Cut(strings.Trim(u.Path, "/"), "/")
+	if bucket == "" {
+		return nil, fmt.Errorf("no bucket in %q", target)
+	}
+	return &mirrorStore{
+		endpoint: &url.URL{Scheme: u.Scheme, Host: u.Host},
+		bucket:   bucket,
+		prefix:   prefix,
+		region:   region,
+		creds:    creds,
+	}, nil
+}
+
+// location is where an object of bucketName is stored in the mirror:
+// <prefix>/<bucket>/<key>
+func (m *mirrorStore) location(bucketName, key string) string {
+	if m.prefix == "" {
+		return bucketName + "/" + key
+	}
+	return m.prefix + "/" + bucketName + "/" + key
+}
+
+// copy streams job's object into the mirror, hashing and scanning it on the
+// way as a download to disk would. It returns what was stored (nil if
+// nothing was) and whether the object was readable at all.
+func (m *mirrorStore) copy(ctx context.Context, config *Config, job downloadJob) (*downloadInfo, bool) {
+	req, err := http.NewRequestWithContext(ctx, "GET", job.fileURL, nil)
+	if err != nil {
+		return nil, false
+	}
+	client := config.client
+	if config.bandwidth != nil {
+		client = config.streamClient
+	}
+	resp, err := doWithRetries(config, client, req)
+	if err != nil {
+		return nil, false
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, false
+	}
+
+	// S3 needs the length of an unsigned upload up front
+	meta := metadataFromResponse(resp)
+	if resp.ContentLength < 0 || resp.ContentLength > maxMirrorObject {
+		m.warn(config, job, fmt.Errorf("size %d can't be stored with a single PUT", resp.ContentLength))
+		return nil, true
+	}
+
+	sha := sha256.New()
+	md := md5.New()
+	sinks := []io.Writer{sha, md}
+	var secrets *secretScanner
+	if config.secretRules != nil {
+		secrets = newSecretScanner(config.secretRules)
+		sinks = append(sinks, secrets)
+	}
+	body := io.TeeReader(throttle(config, resp.Body), io.MultiWriter(sinks...))
+
+	key := m.location(job.bucketName, job.key)
+	if err := m.put(ctx, config, key, body, resp.ContentLength, job.fileURL); err != nil {
+		m.warn(config, job, err)
+		return nil, true
+	}
+
+	info := &downloadInfo{
+		path:   fmt.Sprintf("s3://%s/%s", m.bucket, key),
+		size:   resp.ContentLength,
+		sha256: hex.EncodeToString(sha.Sum(nil)),
+		md5:    hex.EncodeToString(md.Sum(nil)),
+		etag:   resp.Header.Get("ETag"),
+	}
+	info.integrity = checkIntegrity(info.etag, info.md5, meta)
+	if secrets != nil {
+		info.secrets = secrets.hits
+	}
+	return info, true
+}
+
+// put uploads body as key, recording where it came from in its metadata
+func (m *mirrorStore) put(ctx context.Context, config *Config, key string, body io.Reader, size int64, sourceURL string) error {
+	u := *m.endpoint
+	u.Path = "/" + m.bucket + "/" + key
+	u.RawPath = awsURIEncode(u.Path, false)
+	req, err := http.NewRequestWithContext(ctx, "PUT", u.String(), body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("x-amz-meta-source-url", sourceURL)
+	signRequestPayload(req, m.creds, m.region, "s3", unsignedPayload, time.Now())
+
+	resp, err := config.streamClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("PUT %s returned %s %s", u.Path, resp.Status, evidenceSnippet(string(data)))
+	}
+	return nil
+}
+
+func (m *mirrorStore) warn(config *Config, job downloadJob, err error) {
+	msg := fmt.Sprintf("Could not mirror %s: %v", job.fileURL, err)
+	fmt.Println(msg)
+	if config.logger != nil {
+		config.logger.Println(msg)
+	}
+}
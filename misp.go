@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// MISP event JSON, as accepted by /events/add and the "Import from MISP JSON" UI
+type mispEventWrapper struct {
+	Event mispEvent `json:"Event"`
+}
+
+type mispEvent struct {
+	UUID          string          `json:"uuid"`
+	Info          string          `json:"info"`
+	Date          string          `json:"date"`
+	ThreatLevelID string          `json:"threat_level_id"`
+	Analysis      string          `json:"analysis"`
+	Distribution  string          `json:"distribution"`
+	Attribute     []mispAttribute `json:"Attribute"`
+	Tag           []mispTag       `json:"Tag,omitempty"`
+}
+
+type mispAttribute struct {
+	UUID     string `json:"uuid"`
+	Type     string `json:"type"`
+	Category string `json:"category"`
+	Value    string `json:"value"`
+	Comment  string `json:"comment,omitempty"`
+	ToIDS    bool   `json:"to_ids"`
+}
+
+type mispTag struct {
+	Name string `json:"name"`
+}
+
+// buildMISPEvent turns the scan's findings into a single MISP event with one
+// attribute per exposed URL and per downloaded file hash
+func buildMISPEvent(findings []Finding, info string) mispEventWrapper {
+	event := mispEvent{
+		UUID:          newUUID(),
+		Info:          info,
+		Date:          time.Now().UTC().Format("2006-01-02"),
+		ThreatLevelID: "3", // Low
+		Analysis:      "2", // Completed
+		Distribution:  "0", // Your organisation only
+		Attribute:     []mispAttribute{},
+		Tag:           []mispTag{{Name: "tlp:amber"}},
+	}
+
+	worst := -1
+	seen := make(map[string]bool)
+	add := func(attr mispAttribute) {
+		if seen[attr.Type+attr.Value] {
+			return
+		}
+		seen[attr.Type+attr.Value] = true
+		attr.UUID = newUUID()
+		event.Attribute = append(event.Attribute, attr)
+	}
+
+	for _, f := range findings {
+		if r := severityRank(f.Severity); r > worst {
+			worst = r
+		}
+
+		add(mispAttribute{
+			Type:     "url",
+			Category: "Network activity",
+			Value:    f.URL,
+			Comment:  fmt.Sprintf("[%s] %s %s (%s): %s", f.Grade, f.Provider, f.Type, f.Severity, f.Message),
+		})
+
+		if f.SHA256 != "" {
+			name := path.Base(f.Key)
+			add(mispAttribute{
+				Type:     "filename|sha256",
+				Category: "Artifacts dropped",
+				Value:    name + "|" + f.SHA256,
+				Comment:  "Downloaded from " + f.URL,
+			})
+		}
+		if f.MD5 != "" {
+			name := path.Base(f.Key)
+			add(mispAttribute{
+				Type:     "filename|md5",
+				Category: "Artifacts dropped",
+				Value:    name + "|" + f.MD5,
+				Comment:  "Downloaded from " + f.URL,
+			})
+		}
+	}
+
+	// Threat levels run from 1 (High) to 4 (Undefined)
+	switch {
+	case worst >= severityRank("high"):
+		event.ThreatLevelID = "1"
+	case worst >= severityRank("medium"):
+		event.ThreatLevelID = "2"
+	}
+
+	return mispEventWrapper{Event: event}
+}
+
+// pushMISPEvent creates the event on a MISP instance through its REST API
+func pushMISPEvent(baseURL, apiKey string, event mispEventWrapper) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", strings.TrimRight(baseURL, "/")+"/events/add", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", apiKey)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// newUUID returns a random (version 4) UUID
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
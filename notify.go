@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How many findings may wait for the notifiers before recording another blocks
+const notifyBacklog = 1000
+
+// NotifyConfig is the on-disk format of the --notify-config file. Notifiers
+// are named destinations; routes decide which findings go to which of them.
+//
+//	{
+//	  "notifiers": {
+//	    "oncall": {"type": "pagerduty", "routing_key": "..."},
+//	    "search": {"type": "elasticsearch", "url": "http://localhost:9200", "index": "buckets"},
+//	    "acme":   {"type": "slack", "url": "https://hooks.slack.com/services/..."}
+//	  },
+//	  "routes": [
+//	    {"min_severity": "high", "notifiers": ["oncall"]},
+//	    {"notifiers": ["search"]},
+//	    {"bucket": "acme-*", "notifiers": ["acme"]}
+//	  ]
+//	}
+type NotifyConfig struct {
+	Notifiers map[string]NotifierConfig `json:"notifiers"`
+	Routes    []NotifyRoute             `json:"routes"`
+}
+
+type NotifierConfig struct {
+	Type       string            `json:"type"`
+	URL        string            `json:"url"`
+	Index      string            `json:"index"`
+	RoutingKey string            `json:"routing_key"`
+	Headers    map[string]string `json:"headers"`
+}
+
+// NotifyRoute sends findings matching all of its filters to the listed
+// notifiers. Empty filters match everything.
+type NotifyRoute struct {
+	MinSeverity string   `json:"min_severity"`
+	Bucket      string   `json:"bucket"`
+	Types       []string `json:"types"`
+	Notifiers   []string `json:"notifiers"`
+}
+
+type notifyRouter struct {
+	notifiers map[string]NotifierConfig
+	routes    []NotifyRoute
+	client    *http.Client
+
+	mu      sync.Mutex
+	closed  bool
+	pending chan Finding
+	done    chan struct{}
+}
+
+func loadNotifyConfig(filename string) (*notifyRouter, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var nc NotifyConfig
+	if err := json.Unmarshal(data, &nc); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", filename, err)
+	}
+
+	for name, n := range nc.Notifiers {
+		switch n.Type {
+		case "slack", "webhook", "elasticsearch":
+			if n.URL == "" {
+				return nil, fmt.Errorf("notifier %q: url is required", name)
+			}
+		case "pagerduty":
+			if n.RoutingKey == "" {
+				return nil, fmt.Errorf("notifier %q: routing_key is required", name)
+			}
+		default:
+			return nil, fmt.Errorf("notifier %q: unknown type %q", name, n.Type)
+		}
+	}
+
+	for i, route := range nc.Routes {
+		if route.MinSeverity != "" && severityRank(route.MinSeverity) < 0 {
+			return nil, fmt.Errorf("route %d: unknown severity %q", i+1, route.MinSeverity)
+		}
+		if _, err := path.Match(route.Bucket, ""); err != nil {
+			return nil, fmt.Errorf("route %d: bad bucket pattern %q", i+1, route.Bucket)
+		}
+		for _, name := range route.Notifiers {
+			if _, ok := nc.Notifiers[name]; !ok {
+				return nil, fmt.Errorf("route %d: unknown notifier %q", i+1, name)
+			}
+		}
+	}
+
+	return &notifyRouter{
+		notifiers: nc.Notifiers,
+		routes:    nc.Routes,
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+func (r NotifyRoute) matches(f Finding) bool {
+	if r.MinSeverity != "" && severityRank(f.Severity) < severityRank(r.MinSeverity) {
+		return false
+	}
+	if r.Bucket != "" {
+		if ok, _ := path.Match(r.Bucket, f.Bucket); !ok {
+			return false
+		}
+	}
+	if len(r.Types) > 0 {
+		found := false
+		for _, t := range r.Types {
+			if t == f.Type {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// start sends queued findings from a goroutine of its own, so a slow webhook
+// doesn't hold up the probes
+func (n *notifyRouter) start(config *Config) {
+	n.pending = make(chan Finding, notifyBacklog)
+	n.done = make(chan struct{})
+	go func() {
+		defer close(n.done)
+		for f := range n.pending {
+			n.dispatch(config, f)
+		}
+	}()
+}
+
+// notify queues a finding for the notifiers
+func (n *notifyRouter) notify(f Finding) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if !n.closed {
+		n.pending <- f
+	}
+}
+
+// drain waits for the queued findings to be sent; later ones are dropped
+func (n *notifyRouter) drain() {
+	n.mu.Lock()
+	if n.closed {
+		n.mu.Unlock()
+		return
+	}
+	n.closed = true
+	if queued := len(n.pending); queued > 0 {
+		fmt.Printf("Sending %d queued notification(s)...\n", queued)
+	}
+	close(n.pending)
+	n.mu.Unlock()
+	<-n.done
+}
+
+// dispatch sends a finding to every notifier selected by a matching route,
+// notifying each destination at most once
+func (n *notifyRouter) dispatch(config *Config, f Finding) {
+	sent := make(map[string]bool)
+	for _, route := range n.routes {
+		if !route.matches(f) {
+			continue
+		}
+		for _, name := range route.Notifiers {
+			if sent[name] {
+				continue
+			}
+			sent[name] = true
+
+			if err := n.send(n.notifiers[name], f); err != nil {
+				msg := fmt.Sprintf("Notifier %s failed for %s: %v", name, f.Bucket, err)
+				if config.verbose {
+					fmt.Println(msg)
+				}
+				if config.logger != nil {
+					config.logger.Println(msg)
+				}
+			}
+		}
+	}
+}
+
+func (n *notifyRouter) send(nc NotifierConfig, f Finding) error {
+	var target string
+	var payload interface{}
+
+	switch nc.Type {
+	case "slack":
+		target = nc.URL
+		payload = map[string]string{
+			"text": fmt.Sprintf("[%s] %s %s: %s", strings.ToUpper(f.Severity), f.Grade, f.Type, f.Message),
+		}
+	case "pagerduty":
+		target = "https://events.pagerduty.com/v2/enqueue"
+		if nc.URL != "" {
+			target = nc.URL
+		}
+		payload = map[string]interface{}{
+			"routing_key":  nc.RoutingKey,
+			"event_action": "trigger",
+			"dedup_key":    f.Type + ":" + f.URL,
+			"payload": map[string]interface{}{
+				"summary":        f.Message,
+				"source":         f.URL,
+				"severity":       pagerDutySeverity(f.Severity),
+				"component":      f.Bucket,
+				"custom_details": f,
+			},
+		}
+	case "elasticsearch":
+		index := nc.Index
+		if index == "" {
+			index = "bucket_finder"
+		}
+		target = strings.TrimRight(nc.URL, "/") + "/" + index + "/_doc"
+		payload = f
+	default:
+		target = nc.URL
+		payload = f
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range nc.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// pagerDutySeverity maps finding severities onto the four PagerDuty accepts
+func pagerDutySeverity(severity string) string {
+	switch severity {
+	case "critical":
+		return "critical"
+	case "high":
+		return "error"
+	case "medium":
+		return "warning"
+	default:
+		return "info"
+	}
+}
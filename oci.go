@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Oracle Cloud regions with an Object Storage endpoint
+var ociRegions = []string{
+	"us-ashburn-1", "us-phoenix-1", "us-sanjose-1", "us-chicago-1", "ca-toronto-1", "ca-montreal-1",
+	"sa-saopaulo-1", "sa-vinhedo-1", "sa-santiago-1", "sa-bogota-1", "mx-queretaro-1", "mx-monterrey-1",
+	"uk-london-1", "uk-cardiff-1", "eu-frankfurt-1", "eu-amsterdam-1", "eu-zurich-1", "eu-madrid-1",
+	"eu-marseille-1", "eu-milan-1", "eu-paris-1", "eu-stockholm-1", "eu-jovanovac-1", "me-dubai-1",
+	"me-jeddah-1", "me-abudhabi-1", "il-jerusalem-1", "af-johannesburg-1", "ap-mumbai-1",
+	"ap-hyderabad-1", "ap-tokyo-1", "ap-osaka-1", "ap-seoul-1", "ap-chuncheon-1", "ap-singapore-1",
+	"ap-sydney-1", "ap-melbourne-1",
+}
+
+// Probing every region for every namespace is expensive, so by default only
+// the busiest regions are tried
+var ociDefaultRegions = []string{"us-ashburn-1", "us-phoenix-1", "eu-frankfurt-1", "uk-london-1", "ap-tokyo-1"}
+
+var nonNamespaceChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Object fields asked of ListObjects
+const ociListFields = "fields=name,size,etag,md5,timeCreated"
+
+// ociObjectList is the JSON body of ListObjects
+type ociObjectList struct {
+	Objects []struct {
+		Name        string `json:"name"`
+		Size        int64  `json:"size"`
+		MD5         string `json:"md5"`
+		ETag        string `json:"etag"`
+		TimeCreated string `json:"timeCreated"`
+	} `json:"objects"`
+	NextStartWith string `json:"nextStartWith"`
+}
+
+type ociError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ociProvider probes OCI Object Storage at
+// objectstorage.<region>.oraclecloud.com/n/<namespace>/b/<bucket>/o. Every
+// bucket sits in a tenancy namespace, which anonymous users have to guess;
+// older tenancies use the tenancy name, so namespaces are derived from the
+// keywords unless given with --oci-namespace. OCI answers BucketNotFound for
+// both missing and private buckets, so only public buckets can be detected.
+type ociProvider struct {
+	regions    []string
+	namespaces []string
+}
+
+func newOCIProvider(config *Config) (Provider, error) {
+	regions := ociDefaultRegions
+	if config.ociRegions == "all" {
+		regions = ociRegions
+	} else if config.ociRegions != "" {
+		var err error
+		if regions, err = selectRegions(config.ociRegions, ociRegions, "OCI region"); err != nil {
+			return nil, err
+		}
+	}
+
+	var namespaces []string
+	if config.ociNamespaces != "" {
+		for _, ns := range strings.Split(config.ociNamespaces, ",") {
+			if ns = strings.ToLower(strings.TrimSpace(ns)); ns != "" {
+				namespaces = append(namespaces, ns)
+			}
+		}
+	} else {
+		namespaces = guessOCINamespaces(parseKeywords(config.keyword))
+	}
+	if len(namespaces) == 0 {
+		return nil, fmt.Errorf("the oci provider needs --oci-namespace or -k to guess namespaces from")
+	}
+
+	return &ociProvider{regions: regions, namespaces: namespaces}, nil
+}
+
+// guessOCINamespaces derives likely tenancy namespaces from keywords
+func guessOCINamespaces(keywords []string) []string {
+	seen := make(map[string]bool)
+	var namespaces []string
+	add := func(ns string) {
+		ns = nonNamespaceChars.ReplaceAllString(strings.ToLower(ns), "")
+		if len(ns) >= 3 && !seen[ns] {
+			seen[ns] = true
+			namespaces = append(namespaces, ns)
+		}
+	}
+
+	for _, keyword := range keywords {
+		keyword = strings.ToLower(keyword)
+		add(keyword)
+		add(extractBaseName(keyword))
+		if strings.Contains(keyword, ".") {
+			add(strings.Split(keyword, ".")[0])
+		}
+	}
+	return namespaces
+}
+
+func (p *ociProvider) Name() string {
+	return "oci"
+}
+
+func (p *ociProvider) Probe(ctx context.Context, config *Config, bucketName string, workerId int) error {
+	for _, region := range p.regions {
+		for _, ns := range p.namespaces {
+			// The bucket's URL is its object path, so object URLs are <bucket URL>/<key>
+			host := fmt.Sprintf("https://objectstorage.%s.oraclecloud.com/n/%s/b/%s/o", region, url.PathEscape(ns), bucketPlaceholder)
+
+			found, err := p.probeOne(ctx, config, host, bucketName, workerId)
+			if err != nil {
+				return err
+			}
+			if found {
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+func (p *ociProvider) probeOne(ctx context.Context, config *Config, host, bucketName string, workerId int) (bool, error) {
+	listURL := bucketURL(host, bucketName) + "?" + ociListFields
+	resp, body, err := fetchURL(ctx, config, "GET", listURL)
+	if err != nil {
+		return false, err
+	}
+	if config.evidence != nil {
+		defer config.evidence.forget(listURL)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var ociErr ociError
+		json.Unmarshal(body, &ociErr)
+		if config.verbose {
+			fmt.Printf("[Worker %d] %s: %s %s\n", workerId, bucketURL(host, bucketName), resp.Status, ociErr.Code)
+		}
+		return false, nil
+	}
+
+	listResult, err := ociListing(config, host, bucketName, body)
+	if err != nil {
+		return false, nil
+	}
+	processListing(ctx, config, listResult, string(body), bucketName, host, 0, workerId)
+	return true, nil
+}
+
+// ociListing converts a page of ListObjects into a bucket listing that
+// continues from nextStartWith
+func ociListing(config *Config, host, bucketName string, body []byte) (ListBucketResult, error) {
+	var list ociObjectList
+	if err := json.Unmarshal(body, &list); err != nil {
+		return ListBucketResult{}, err
+	}
+
+	listResult := ListBucketResult{Name: bucketName, IsTruncated: list.NextStartWith != ""}
+	for _, obj := range list.Objects {
+		listResult.Contents = append(listResult.Contents, ListBucketObject{
+			Key:          obj.Name,
+			LastModified: obj.TimeCreated,
+			ETag:         obj.ETag,
+			Size:         obj.Size,
+		})
+	}
+	if listResult.IsTruncated {
+		listResult.nextPage = func(ctx context.Context) (ListBucketResult, error) {
+			pageURL := bucketURL(host, bucketName) + "?" + ociListFields + "&start=" + url.QueryEscape(list.NextStartWith)
+			resp, body, err := fetchURL(ctx, config, "GET", pageURL)
+			if config.evidence != nil {
+				config.evidence.forget(pageURL)
+			}
+			if err != nil {
+				return ListBucketResult{}, err
+			}
+			if resp.StatusCode != http.StatusOK {
+				return ListBucketResult{}, fmt.Errorf("listing page returned %s", resp.Status)
+			}
+			return ociListing(config, host, bucketName, body)
+		}
+	}
+	return listResult, nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// Alibaba Cloud OSS regions; a bucket lives in exactly one of them
+var ossRegions = []string{
+	"cn-hangzhou", "cn-shanghai", "cn-nanjing", "cn-qingdao", "cn-beijing", "cn-zhangjiakou",
+	"cn-huhehaote", "cn-wulanchabu", "cn-shenzhen", "cn-heyuan", "cn-guangzhou", "cn-chengdu",
+	"cn-hongkong", "us-west-1", "us-east-1", "ap-northeast-1", "ap-northeast-2", "ap-southeast-1",
+	"ap-southeast-3", "ap-southeast-5", "ap-southeast-6", "ap-southeast-7", "eu-central-1",
+	"eu-west-1", "me-east-1",
+}
+
+// ossProvider probes Alibaba Cloud OSS using virtual-hosted URLs. A bucket
+// asked for at the wrong regional endpoint answers AccessDenied naming the
+// right one, so a single request per candidate finds the bucket's region.
+type ossProvider struct {
+	region string
+}
+
+func newOSSProvider(config *Config) (Provider, error) {
+	region := strings.ToLower(strings.TrimPrefix(config.ossRegion, "oss-"))
+	if !containsString(ossRegions, region) {
+		return nil, fmt.Errorf("unknown OSS region %q (choose from %s)", config.ossRegion, strings.Join(ossRegions, ", "))
+	}
+	return &ossProvider{region: region}, nil
+}
+
+func (p *ossProvider) Name() string {
+	return "oss"
+}
+
+func (p *ossProvider) Probe(ctx context.Context, config *Config, bucketName string, workerId int) error {
+	host := fmt.Sprintf("https://%s.oss-%s.aliyuncs.com", bucketPlaceholder, p.region)
+
+	data, err := getPage(ctx, config, bucketURL(host, bucketName), "")
+	if err != nil {
+		return err
+	}
+
+	var ossError S3Error
+	if err := xml.Unmarshal([]byte(data), &ossError); err == nil && ossError.Code != "" {
+		switch {
+		case ossError.Code == "NoSuchBucket" || ossError.Code == "InvalidBucketName":
+			if config.verbose {
+				fmt.Printf("[Worker %d] Bucket does not exist on OSS: %s\n", workerId, bucketName)
+			}
+			p.forget(config, host, bucketName)
+			return nil
+
+		case ossError.Endpoint != "" && !strings.Contains(host, ossError.Endpoint):
+			// Wrong region: ask the endpoint OSS pointed us at instead
+			p.forget(config, host, bucketName)
+			if config.verbose {
+				fmt.Printf("[Worker %d] OSS bucket %s lives at %s\n", workerId, bucketName, ossError.Endpoint)
+			}
+			host = fmt.Sprintf("https://%s.%s", bucketPlaceholder, ossError.Endpoint)
+			data, err = getPage(ctx, config, bucketURL(host, bucketName), "")
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if data != "" {
+		parseResults(ctx, config, data, bucketName, host, 0, workerId)
+	}
+	p.forget(config, host, bucketName)
+	return nil
+}
+
+func (p *ossProvider) forget(config *Config, host, bucketName string) {
+	if config.evidence != nil {
+		config.evidence.forget(bucketURL(host, bucketName) + "/")
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Characters that can't appear in a file name on some platform we write to.
+// Backslashes would be separators on Windows and colons drive letters or
+// alternate data streams.
+var unsafeNameChars = strings.NewReplacer(`\`, "_", ":", "_", "\x00", "_")
+
+// safeName turns one segment of an object key into a file or directory name,
+// or "" if nothing usable is left. "." and ".." are dropped so a key can
+// never step out of the directory it is written to.
+func safeName(segment string) string {
+	segment = unsafeNameChars.Replace(segment)
+	if segment == "." || segment == ".." {
+		return ""
+	}
+	return segment
+}
+
+// localPath builds a relative path from the "/"-separated segments of keys
+// and URL paths, keeping only the safe ones, or "" if there are none
+func localPath(parts ...string) string {
+	var elems []string
+	for _, part := range parts {
+		for _, segment := range strings.Split(part, "/") {
+			if name := safeName(segment); name != "" {
+				elems = append(elems, name)
+			}
+		}
+	}
+
+	return filepath.Join(elems...)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Environment names substituted for %env%
+var envWords = []string{"prod", "production", "staging", "stage", "dev", "test", "qa", "uat", "backup"}
+
+var patternVariable = regexp.MustCompile(`%([a-z]+)%`)
+
+// Variables understood in patterns
+var patternVariables = []string{"kw", "base", "env", "year", "region", "number"}
+
+// validatePattern checks that a pattern only uses known variables and is
+// built on the keyword
+func validatePattern(pattern string) error {
+	for _, m := range patternVariable.FindAllStringSubmatch(pattern, -1) {
+		if !containsString(patternVariables, m[1]) {
+			return fmt.Errorf("pattern %q: unknown variable %%%s%% (use %s)", pattern, m[1],
+				"%"+strings.Join(patternVariables, "%, %")+"%")
+		}
+	}
+	if !strings.Contains(pattern, "%kw%") && !strings.Contains(pattern, "%base%") {
+		return fmt.Errorf("pattern %q does not contain %%kw%% or %%base%%", pattern)
+	}
+	return nil
+}
+
+// expandPattern fills in a pattern such as "%kw%-%env%-%year%" for keyword,
+// producing one name per combination of the variables it uses
+func expandPattern(pattern, keyword string, rules *PermutationRules) []string {
+	values := map[string][]string{
+		"kw":   {keyword},
+		"base": {extractBaseName(keyword)},
+	}
+	if strings.Contains(pattern, "%env%") {
+		values["env"] = envWords
+	}
+	if strings.Contains(pattern, "%year%") {
+		from, to := rules.yearRange()
+		for year := from; year <= to; year++ {
+			values["year"] = append(values["year"], strconv.Itoa(year))
+		}
+	}
+	if strings.Contains(pattern, "%region%") {
+		values["region"] = awsRegions
+	}
+	if strings.Contains(pattern, "%number%") {
+		numbers := "1-2"
+		if rules != nil && rules.Numbers != "" {
+			numbers = rules.Numbers
+		}
+		if from, to, err := parseRange(numbers); err == nil {
+			for i := from; i <= to; i++ {
+				values["number"] = append(values["number"], strconv.Itoa(i))
+			}
+		}
+	}
+
+	names := []string{pattern}
+	for _, variable := range patternVariables {
+		placeholder := "%" + variable + "%"
+		if !strings.Contains(pattern, placeholder) {
+			continue
+		}
+
+		var expanded []string
+		for _, name := range names {
+			for _, value := range values[variable] {
+				expanded = append(expanded, strings.ReplaceAll(name, placeholder, value))
+			}
+		}
+		names = expanded
+	}
+	return names
+}
+
+// generatePatternCandidates builds names for every keyword from --pattern
+// templates alone, leaving out the built-in permutations
+func generatePatternCandidates(keywords, patterns []string, rules *PermutationRules) []string {
+	perms := make(map[string]bool)
+	for _, keyword := range keywords {
+		keyword = strings.ToLower(strings.Join(strings.Fields(keyword), "-"))
+		for _, pattern := range patterns {
+			for _, name := range expandPattern(pattern, keyword, rules) {
+				addPermutation(perms, name)
+			}
+		}
+	}
+
+	var result []string
+	for name := range perms {
+		if rules.validName(name) {
+			result = append(result, name)
+		}
+	}
+	return result
+}
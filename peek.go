@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Lines of preview shown per object at most
+const peekLines = 8
+
+// peekObject fetches the first n bytes of a readable object
+func peekObject(ctx context.Context, config *Config, fileURL string, n int) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", fileURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=0-%d", n-1))
+
+	resp, body, err := doRequest(config, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		return nil, fmt.Errorf("%s", resp.Status)
+	}
+	// Servers ignoring Range send the whole object
+	if len(body) > n {
+		body = body[:n]
+	}
+	return body, nil
+}
+
+// formatPeek renders a preview as text if it looks like text, or as a hex
+// dump otherwise, each line starting with indent
+func formatPeek(data []byte, indent string) string {
+	var lines []string
+	if looksLikeText(data) {
+		for _, line := range strings.Split(strings.TrimRight(string(data), "\r\n"), "\n") {
+			lines = append(lines, strings.TrimRight(line, "\r"))
+		}
+	} else {
+		lines = strings.Split(strings.TrimRight(hex.Dump(data), "\n"), "\n")
+	}
+
+	if len(lines) > peekLines {
+		lines = append(lines[:peekLines], "...")
+	}
+	return indent + strings.Join(lines, "\n"+indent)
+}
+
+// looksLikeText reports whether data is UTF-8 text, allowing for a
+// character cut in half at the end
+func looksLikeText(data []byte) bool {
+	for i := 0; i < len(data); {
+		r, size := utf8.DecodeRune(data[i:])
+		if r == utf8.RuneError && size <= 1 {
+			return len(data)-i < utf8.UTFMax && !utf8.FullRune(data[i:])
+		}
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return false
+		}
+		i += size
+	}
+	return true
+}
+
+// printPeek shows the start of a readable object below its output line
+func printPeek(ctx context.Context, config *Config, fileURL, workerPrefix, tabs string) {
+	data, err := peekObject(ctx, config, fileURL, config.peek)
+	if err != nil {
+		if config.verbose {
+			fmt.Printf("%s%s\tCould not preview %s: %v\n", workerPrefix, tabs, fileURL, err)
+		}
+		return
+	}
+	if len(data) == 0 {
+		return
+	}
+
+	msg := formatPeek(data, workerPrefix+tabs+"\t| ")
+	fmt.Println(msg)
+	if config.logger != nil {
+		config.logger.Println(msg)
+	}
+}
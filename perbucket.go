@@ -0,0 +1,187 @@
+package main
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// bucketReport is everything learnt about one bucket, written to its own file
+// by --per-bucket-dir
+type bucketReport struct {
+	Provider    string         `json:"provider,omitempty"`
+	Bucket      string         `json:"bucket"`
+	URL         string         `json:"url"`
+	Access      string         `json:"access"`
+	Region      string         `json:"region,omitempty"`
+	Grade       string         `json:"grade,omitempty"`
+	Partial     bool           `json:"partial,omitempty"`
+	ObjectCount int            `json:"object_count"`
+	TotalSize   int64          `json:"total_size"`
+	Contents    *bucketStats   `json:"contents,omitempty"`
+	Objects     []objectReport `json:"objects"`
+	Findings    []Finding      `json:"findings"`
+	GeneratedAt time.Time      `json:"generated_at"`
+}
+
+type objectReport struct {
+	Key          string          `json:"key"`
+	URL          string          `json:"url"`
+	Size         int64           `json:"size"`
+	LastModified string          `json:"last_modified,omitempty"`
+	ETag         string          `json:"etag,omitempty"`
+	Access       string          `json:"access"`
+	Encryption   string          `json:"encryption,omitempty"`
+	Metadata     *objectMetadata `json:"metadata,omitempty"`
+}
+
+type bucketReportStore struct {
+	dir string
+	// Name the files <provider>_<bucket>.json, for scans of several providers
+	byProvider bool
+
+	mu      sync.Mutex
+	reports map[string]*bucketReport // by bucketKey
+	// Downloads still to finish per bucket, and the probed buckets whose
+	// reports wait for them, as downloads can still add findings
+	downloading map[string]int
+	waiting     map[string]bool
+}
+
+func newBucketReportStore(dir string) *bucketReportStore {
+	return &bucketReportStore{
+		dir:         dir,
+		reports:     make(map[string]*bucketReport),
+		downloading: make(map[string]int),
+		waiting:     make(map[string]bool),
+	}
+}
+
+// get returns the report for a provider's bucket, creating it on first use.
+// Callers must hold mu.
+func (s *bucketReportStore) get(provider, bucket string) *bucketReport {
+	key := bucketKey(provider, bucket)
+	r, ok := s.reports[key]
+	if !ok {
+		r = &bucketReport{Provider: provider, Bucket: bucket, Objects: []objectReport{}, Findings: []Finding{}}
+		s.reports[key] = r
+	}
+	return r
+}
+
+func (s *bucketReportStore) setAccess(provider, bucket, url, access string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.get(provider, bucket)
+	r.URL = url
+	r.Access = access
+}
+
+func (s *bucketReportStore) setPartial(provider, bucket string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.get(provider, bucket).Partial = true
+}
+
+func (s *bucketReportStore) addObject(provider, bucket string, obj objectReport) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.get(provider, bucket)
+	r.Objects = append(r.Objects, obj)
+	r.ObjectCount++
+	r.TotalSize += obj.Size
+}
+
+func (s *bucketReportStore) addFinding(f Finding) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.get(f.Provider, f.Bucket)
+	if r.URL == "" {
+		r.URL = f.URL
+	}
+	r.Findings = append(r.Findings, f)
+	if f.Region != "" {
+		r.Region = f.Region
+	}
+	if f.Stats != nil {
+		r.Contents = f.Stats
+	}
+	r.Grade = worseGrade(r.Grade, f)
+}
+
+// hold keeps bucket's reports from being flushed until a queued download
+// of one of its objects is released
+func (s *bucketReportStore) hold(bucket string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.downloading[bucket]++
+}
+
+// release marks a download from bucket finished, writing the bucket's reports
+// if it was probed and this was the last one
+func (s *bucketReportStore) release(bucket string) error {
+	s.mu.Lock()
+	if s.downloading[bucket]--; s.downloading[bucket] > 0 {
+		s.mu.Unlock()
+		return nil
+	}
+	delete(s.downloading, bucket)
+	waiting := s.waiting[bucket]
+	delete(s.waiting, bucket)
+	s.mu.Unlock()
+
+	if !waiting {
+		return nil
+	}
+	return s.flush(bucket)
+}
+
+// flush writes the reports for bucket on every provider it was probed on,
+// if anything was learnt about it, or once its downloads are done
+func (s *bucketReportStore) flush(bucket string) error {
+	s.mu.Lock()
+	if s.downloading[bucket] > 0 {
+		s.waiting[bucket] = true
+		s.mu.Unlock()
+		return nil
+	}
+	var reports []*bucketReport
+	for key, r := range s.reports {
+		if r.Bucket == bucket {
+			reports = append(reports, r)
+			delete(s.reports, key)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, r := range reports {
+		if err := s.write(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *bucketReportStore) write(r *bucketReport) error {
+	name := r.Bucket
+	if s.byProvider {
+		name = r.Provider + "_" + name
+	}
+	r.GeneratedAt = time.Now().UTC()
+	return writeJSONFile(filepath.Join(s.dir, unsafePathChars.ReplaceAllString(name, "_")+".json"), r)
+}
+
+// flushAll writes every report still pending, e.g. buckets reported without being probed
+func (s *bucketReportStore) flushAll() error {
+	s.mu.Lock()
+	reports := s.reports
+	s.reports = make(map[string]*bucketReport)
+	s.mu.Unlock()
+
+	for _, r := range reports {
+		if err := s.write(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Provider is an object storage service that candidate bucket names are probed against
+type Provider interface {
+	// Name is the identifier used by --provider
+	Name() string
+	// Probe checks a single candidate bucket, reporting anything it finds.
+	// An error means the candidate could not be checked at all.
+	Probe(ctx context.Context, config *Config, bucketName string, workerId int) error
+}
+
+// providerFactories builds each selectable provider from the parsed config
+var providerFactories = map[string]func(config *Config) (Provider, error){
+	"aws":    newAWSProvider,
+	"azure":  newAzureProvider,
+	"gcs":    newGCSProvider,
+	"spaces": newSpacesProvider,
+	"oss":    newOSSProvider,
+	"r2":     newR2Provider,
+	"linode": newLinodeProvider,
+	"oci":    newOCIProvider,
+	"ibm":    newIBMProvider,
+	"swift":  newSwiftProvider,
+}
+
+func providerNames() []string {
+	var names []string
+	for name := range providerFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// newProvider builds the provider(s) named by --provider: a single name, a
+// comma-separated list, or "all"
+func newProvider(name string, config *Config) (Provider, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	if name == "all" {
+		// Providers that need extra settings (e.g. swift) are left out rather than failing
+		multi := &multiProvider{}
+		for _, n := range providerNames() {
+			p, err := providerFactories[n](config)
+			if err != nil {
+				if config.verbose {
+					fmt.Printf("Skipping provider %s: %v\n", n, err)
+				}
+				continue
+			}
+			multi.providers = append(multi.providers, p)
+		}
+		return multi, nil
+	}
+
+	if strings.Contains(name, ",") {
+		multi := &multiProvider{}
+		for _, n := range strings.Split(name, ",") {
+			if n = strings.TrimSpace(n); n == "" {
+				continue
+			}
+			p, err := newProvider(n, config)
+			if err != nil {
+				return nil, err
+			}
+			multi.providers = append(multi.providers, p)
+		}
+		return multi, nil
+	}
+
+	factory, ok := providerFactories[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown provider %q (choose from %s, or all)", name, strings.Join(providerNames(), ", "))
+	}
+	return factory(config)
+}
+
+// multiProvider fans every candidate out to several providers at once
+type multiProvider struct {
+	providers []Provider
+}
+
+func (m *multiProvider) Name() string {
+	var names []string
+	for _, p := range m.providers {
+		names = append(names, p.Name())
+	}
+	return strings.Join(names, ",")
+}
+
+// Probe runs every provider concurrently, failing only if all of them failed.
+// A name any of them throttled is routed back to just those for another try.
+func (m *multiProvider) Probe(ctx context.Context, config *Config, bucketName string, workerId int) error {
+	errs := make([]error, len(m.providers))
+	var wg sync.WaitGroup
+
+	for i, p := range m.providers {
+		wg.Add(1)
+		go func(i int, p Provider) {
+			defer wg.Done()
+			errs[i] = p.Probe(withProvider(ctx, p.Name()), config, bucketName, workerId)
+			if errs[i] != nil && config.verbose {
+				fmt.Printf("[Worker %d] %s: error probing %s: %v\n", workerId, p.Name(), bucketName, errs[i])
+			}
+		}(i, p)
+	}
+	wg.Wait()
+
+	var throttled []string
+	for i, err := range errs {
+		if errors.Is(err, errSlowDown) {
+			throttled = append(throttled, m.providers[i].Name())
+		}
+	}
+	if len(throttled) > 0 {
+		config.routes.set(bucketName, throttled)
+		return errSlowDown
+	}
+
+	for _, err := range errs {
+		if err == nil {
+			return nil
+		}
+	}
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// bucketKey identifies a bucket by its provider as well as its name, as the
+// same name can be taken on several providers
+func bucketKey(provider, bucket string) string {
+	return provider + "/" + bucket
+}
+
+// splitBucketKey is the reverse of bucketKey
+func splitBucketKey(key string) (provider, bucket string) {
+	provider, bucket, _ = strings.Cut(key, "/")
+	return provider, bucket
+}
+
+type providerContextKey struct{}
+
+// withProvider records in ctx which provider a probe is running for
+func withProvider(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, providerContextKey{}, name)
+}
+
+func providerFromContext(ctx context.Context) string {
+	name, _ := ctx.Value(providerContextKey{}).(string)
+	return name
+}
+
+// s3Provider probes services speaking the S3 XML protocol. Each host is
+// tried in turn, addressed as bucketURL says, until one of them knows the
+// bucket.
+type s3Provider struct {
+	name  string
+	hosts func(bucketName string) []string
+}
+
+func (p *s3Provider) Name() string {
+	return p.name
+}
+
+func (p *s3Provider) Probe(ctx context.Context, config *Config, bucketName string, workerId int) error {
+	for _, host := range p.hosts(bucketName) {
+		pageURL := bucketURL(host, bucketName)
+		resp, body, err := fetchURL(ctx, config, "GET", pageURL)
+		if err != nil {
+			return err
+		}
+		if isSlowDown(resp, body) {
+			return errSlowDown
+		}
+		data := string(body)
+
+		// A wrong-region request is answered with a 301 naming the bucket's region
+		region := ""
+		if p.name == "aws" && resp.StatusCode == http.StatusMovedPermanently {
+			region = resp.Header.Get("x-amz-bucket-region")
+		}
+
+		if region != "" && probeRegion(ctx, config, bucketName, region, host, 0, workerId) {
+			// Re-probed at the right endpoint
+		} else if data != "" {
+			parseResults(ctx, config, data, bucketName, host, 0, workerId)
+		}
+		if config.evidence != nil {
+			config.evidence.forget(pageURL)
+		}
+
+		if s3BucketExists(data) {
+			break
+		}
+	}
+	return nil
+}
+
+// s3BucketExists reports whether an S3-style response shows the bucket exists,
+// i.e. it is anything other than a NoSuchBucket error
+func s3BucketExists(data string) bool {
+	var listResult ListBucketResult
+	if err := xml.Unmarshal([]byte(data), &listResult); err == nil && listResult.Name != "" {
+		return true
+	}
+
+	var s3Error S3Error
+	if err := xml.Unmarshal([]byte(data), &s3Error); err == nil && s3Error.Code != "" {
+		return s3Error.Code != "NoSuchBucket"
+	}
+	return false
+}
+
+func newAWSProvider(config *Config) (Provider, error) {
+	host := getHostForRegion(config.region, config.dualstack)
+	if host == "" {
+		return nil, fmt.Errorf("unknown region %q", config.region)
+	}
+
+	if config.allRegions {
+		// Start with --region so the common case costs a single request
+		hosts := []string{host}
+		for _, region := range awsRegions {
+			if h := getHostForRegion(region, config.dualstack); h != host {
+				hosts = append(hosts, h)
+			}
+		}
+		return &s3Provider{
+			name:  "aws",
+			hosts: func(string) []string { return hosts },
+		}, nil
+	}
+
+	return &s3Provider{
+		name:  "aws",
+		hosts: func(string) []string { return []string{host} },
+	}, nil
+}
+
+// bucketPlaceholder marks where a host template names the bucket. Hosts
+// without it are addressed path-style (host/<bucket>); a template covers
+// virtual-hosted (https://{bucket}.oss-cn-hangzhou.aliyuncs.com) and other
+// bucket-specific URLs.
+const bucketPlaceholder = "{bucket}"
+
+// bucketURL is the URL of bucketName as served by host
+func bucketURL(host, bucketName string) string {
+	if strings.Contains(host, bucketPlaceholder) {
+		return strings.ReplaceAll(host, bucketPlaceholder, bucketName)
+	}
+	return fmt.Sprintf("%s/%s", host, bucketName)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+const (
+	queueMessageNames = 100              // names packed into one queue message
+	maxQueueFailures  = 5                // failed pops in a row before giving up on the queue
+	queueExtendEvery  = 40 * time.Second // how often a batch being probed is kept from other instances
+)
+
+// jobQueue is a work queue shared by several instances of the scanner
+// (--queue): each pushes the candidates it was given and probes whatever
+// it takes off the queue, so they work through one candidate list together
+type jobQueue interface {
+	// push queues names, leaving out any a queue that remembers names has
+	// seen before
+	push(names []string) error
+	// pop takes the next message, waiting a few seconds; nil means none came
+	pop(ctx context.Context) (*queueMessage, error)
+	// ack removes a message once its names have been probed
+	ack(msg *queueMessage) error
+	// extend keeps a message being probed from going to another instance
+	extend(msg *queueMessage) error
+	// claimFinding reports whether no other instance has recorded the
+	// finding with this key yet
+	claimFinding(key string) bool
+}
+
+// queueMessage is a batch of candidate names taken off the queue
+type queueMessage struct {
+	names  []string
+	handle string // the backend's receipt, if it needs one to ack
+}
+
+// newJobQueue connects to the --queue: redis://[:password@]host:port[/db]
+// (rediss:// for TLS) or sqs://sqs.REGION.amazonaws.com/ACCOUNT/QUEUE
+func newJobQueue(config *Config, target string) (jobQueue, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "redis", "rediss":
+		return newRedisQueue(config, u)
+	case "sqs", "sqs+http":
+		return newSQSQueue(config, u)
+	}
+	return nil, fmt.Errorf("unsupported queue %q (use redis://, rediss:// or sqs://)", target)
+}
+
+// queueName is the --queue as shown in messages, without its password
+func queueName(target string) string {
+	if u, err := url.Parse(target); err == nil {
+		return u.Redacted()
+	}
+	return target
+}
+
+// runQueued stands in for processBucketsWithWorkers with --queue: the feed
+// goes into the queue in the background while batches are taken off it and
+// probed, until the queue has stayed empty for --queue-idle
+func runQueued(config *Config, feed *candidateFeed) error {
+	name := queueName(config.queueURL)
+	feed.stop = config.intake.Done()
+	fed := make(chan error, 1)
+	go func() {
+		var names []string
+		var pushErr error
+		err := feed.each(func(name string) {
+			if pushErr != nil {
+				return
+			}
+			if names = append(names, name); len(names) >= queueMessageNames {
+				pushErr = config.queue.push(names)
+				names = nil
+			}
+		})
+		if pushErr == nil && len(names) > 0 {
+			pushErr = config.queue.push(names)
+		}
+		if err == nil && pushErr != nil {
+			err = fmt.Errorf("queueing candidates on %s: %v", name, pushErr)
+		}
+		fed <- err
+	}()
+
+	var feedErr error
+	feeding := true
+	idleSince := time.Now()
+	failures := 0
+	for config.intake.Err() == nil {
+		if feeding {
+			select {
+			case feedErr = <-fed:
+				feeding = false
+				idleSince = time.Now()
+			default:
+			}
+		}
+
+		msg, err := config.queue.pop(config.intake)
+		if err != nil {
+			if config.intake.Err() != nil {
+				break
+			}
+			if failures++; failures >= maxQueueFailures {
+				return fmt.Errorf("giving up on queue %s: %v", name, err)
+			}
+			fmt.Printf("Could not take work from queue %s: %v\n", name, err)
+			sleepUnlessStopped(config, retryDelay(failures))
+			continue
+		}
+		failures = 0
+		if msg == nil {
+			if !feeding && time.Since(idleSince) >= config.queueIdle {
+				break
+			}
+			continue
+		}
+
+		if config.verbose {
+			fmt.Printf("Probing %d name(s) from the queue\n", len(msg.names))
+		}
+		stopExtending := keepQueueMessage(config, msg)
+		err = processBucketsWithWorkers(config, &candidateFeed{names: msg.names})
+		stopExtending()
+		if err != nil {
+			fmt.Printf("Error probing queued names: %v\n", err)
+		}
+		// A batch cut short is left unacked: SQS hands it to another instance
+		// once it is visible again, and Redis keeps it on the processing list
+		if config.intake.Err() != nil {
+			break
+		}
+		if err := config.queue.ack(msg); err != nil {
+			fmt.Printf("Could not remove a finished batch from queue %s: %v\n", name, err)
+		}
+		idleSince = time.Now()
+	}
+	return feedErr
+}
+
+// keepQueueMessage extends msg every queueExtendEvery until the returned
+// func is called
+func keepQueueMessage(config *Config, msg *queueMessage) func() {
+	stop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(queueExtendEvery)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := config.queue.extend(msg); err != nil {
+					fmt.Printf("Could not keep a batch from going back on the queue: %v\n", err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return func() { close(stop) }
+}
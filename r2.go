@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+const findingBucketPublicRead = "bucket-public-read"
+
+// r2.dev development URLs are pub-<32 hex chars>.r2.dev
+var r2DevID = regexp.MustCompile(`^pub-[0-9a-f]{32}$`)
+
+// r2Provider detects Cloudflare R2 buckets exposed to the internet. R2's S3
+// API never answers anonymous requests, so public buckets are only reachable
+// through an r2.dev development URL or a custom domain. Candidates therefore
+// have to be r2.dev IDs/hosts or domain names; plain bucket names are skipped.
+// R2 doesn't support anonymous listing either, so exposure is judged from how
+// the bucket root answers.
+type r2Provider struct{}
+
+func newR2Provider(config *Config) (Provider, error) {
+	return &r2Provider{}, nil
+}
+
+func (p *r2Provider) Name() string {
+	return "r2"
+}
+
+// r2Host maps a candidate onto the host it would be served from, if any
+func r2Host(candidate string) (string, bool) {
+	candidate = strings.ToLower(strings.TrimSuffix(candidate, "/"))
+	switch {
+	case r2DevID.MatchString(candidate):
+		return candidate + ".r2.dev", true
+	case strings.HasSuffix(candidate, ".r2.dev"):
+		return candidate, true
+	case strings.Contains(candidate, "."):
+		return candidate, true
+	default:
+		return "", false
+	}
+}
+
+func (p *r2Provider) Probe(ctx context.Context, config *Config, bucketName string, workerId int) error {
+	host, ok := r2Host(bucketName)
+	if !ok {
+		if config.verbose {
+			fmt.Printf("[Worker %d] Skipping %s: R2 buckets can only be probed by r2.dev ID or custom domain\n", workerId, bucketName)
+		}
+		return nil
+	}
+
+	rootURL := "https://" + host + "/"
+	resp, body, err := fetchURL(ctx, config, "GET", rootURL)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if config.evidence != nil {
+			config.evidence.forget(rootURL)
+		}
+	}()
+
+	devURL := strings.HasSuffix(host, ".r2.dev")
+	if !devURL && !isR2Response(resp, body) {
+		if config.verbose {
+			fmt.Printf("[Worker %d] %s is not served by R2\n", workerId, host)
+		}
+		return nil
+	}
+
+	var msg string
+	switch {
+	case resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusNotFound:
+		// Root answered (or the root object is simply missing): objects are public
+		msg = fmt.Sprintf("R2 bucket publicly readable: %s ( %s )", bucketName, rootURL)
+		recordFinding(ctx, config, Finding{
+			Bucket:   bucketName,
+			URL:      rootURL,
+			Type:     findingBucketPublicRead,
+			Severity: "medium",
+			Message:  fmt.Sprintf("R2 bucket behind %s serves objects to anonymous users", host),
+			Evidence: fmt.Sprintf("GET %s returned %s\n%s", rootURL, resp.Status, evidenceSnippet(string(body))),
+		})
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		msg = fmt.Sprintf("R2 bucket found but public access disabled: %s ( %s )", bucketName, rootURL)
+		recordFinding(ctx, config, Finding{
+			Bucket:   bucketName,
+			URL:      rootURL,
+			Type:     findingBucketExists,
+			Severity: "info",
+			Message:  fmt.Sprintf("R2 bucket behind %s exists but public access is disabled", host),
+			Evidence: fmt.Sprintf("GET %s returned %s", rootURL, resp.Status),
+		})
+	default:
+		if config.verbose {
+			fmt.Printf("[Worker %d] Unexpected R2 response for %s: %s\n", workerId, rootURL, resp.Status)
+		}
+		return nil
+	}
+
+	fmt.Println(msg)
+	if config.logger != nil {
+		config.logger.Println(msg)
+	}
+	return nil
+}
+
+// isR2Response recognises R2 behind a custom domain: served by Cloudflare,
+// and answering errors with S3-style XML rather than an HTML page
+func isR2Response(resp *http.Response, body []byte) bool {
+	if !strings.EqualFold(resp.Header.Get("Server"), "cloudflare") {
+		return false
+	}
+	if resp.StatusCode == http.StatusOK {
+		return resp.Header.Get("Cf-R2-Request-Id") != ""
+	}
+
+	var r2Error S3Error
+	return xml.Unmarshal(body, &r2Error) == nil && r2Error.Code != ""
+}
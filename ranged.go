@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// splitDownload reports whether job is large enough to fetch in parallel
+// ranged parts. Only objects with an ETag qualify, so every part can be
+// pinned to the same version of the object, and a partial file left by an
+// earlier sequential download is resumed rather than started over.
+func splitDownload(config *Config, job downloadJob) (string, bool) {
+	if config.splitParts < 2 || config.splitBytes <= 0 || job.size < config.splitBytes {
+		return "", false
+	}
+	if job.meta == nil || job.meta.ETag == "" {
+		return "", false
+	}
+	_, fileName, err := downloadPath(job.fileURL, job.bucketName, job.key, job.depth)
+	if err != nil {
+		return "", false
+	}
+	offset, _ := resumePoint(fileName, job.fileURL)
+	return fileName, offset == 0
+}
+
+// downloadRanged fetches a large object as config.splitParts ranged GETs at
+// once, each written at its offset in the partial file, then hashes the
+// assembled file. Parts can't be resumed individually, so a download that
+// fails part way is discarded, and nil returned so the caller can fall back
+// to a single GET.
+func downloadRanged(ctx context.Context, config *Config, job downloadJob, fileName string) (*downloadInfo, bool) {
+	if err := os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+		return nil, true
+	}
+
+	// The partial file is marked without an ETag: it fills in out of order,
+	// so an interrupted one is started over rather than resumed
+	etag := `"` + job.meta.ETag + `"`
+	file, err := openPartial(fileName, job.fileURL, "", 0)
+	if err != nil {
+		return nil, true
+	}
+	if err := file.Truncate(job.size); err != nil {
+		file.Close()
+		discardPartial(fileName)
+		return nil, true
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	partSize := (job.size + int64(config.splitParts) - 1) / int64(config.splitParts)
+	errs := make(chan error, config.splitParts)
+	var wg sync.WaitGroup
+	for start := int64(0); start < job.size; start += partSize {
+		end := min(start+partSize, job.size) - 1
+		wg.Add(1)
+		go func(start, end int64) {
+			defer wg.Done()
+			if err := downloadPart(ctx, config, job.fileURL, etag, file, start, end); err != nil {
+				errs <- err
+				cancel() // no point fetching the rest
+			}
+		}(start, end)
+	}
+	wg.Wait()
+	file.Close()
+	close(errs)
+
+	if err := <-errs; err != nil {
+		discardPartial(fileName)
+		if config.verbose {
+			fmt.Printf("Ranged download of %s failed: %v\n", job.fileURL, err)
+		}
+		return nil, true
+	}
+
+	info, err := hashPartial(config, fileName)
+	if err != nil {
+		discardPartial(fileName)
+		return nil, true
+	}
+	if err := finishPartial(fileName); err != nil {
+		return nil, true
+	}
+	info.etag = etag
+	info.integrity = checkIntegrity(etag, info.md5, job.meta)
+	return info, true
+}
+
+// downloadPart fetches bytes start-end of fileURL into file. If-Match makes
+// the request fail if the object changed since its other parts were fetched.
+func downloadPart(ctx context.Context, config *Config, fileURL, etag string, file *os.File, start, end int64) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", fileURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+	req.Header.Set("If-Match", etag)
+
+	// Parts of a large object take as long as they take
+	resp, err := doWithRetries(config, config.streamClient, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		return fmt.Errorf("bytes %d-%d: %s", start, end, resp.Status)
+	}
+	if got, _ := resumedFrom(resp); got != start {
+		return fmt.Errorf("bytes %d-%d: server sent a range from %d", start, end, got)
+	}
+
+	n, err := io.Copy(io.NewOffsetWriter(file, start), io.LimitReader(throttle(config, resp.Body), end-start+1))
+	if err == nil && n != end-start+1 {
+		err = fmt.Errorf("bytes %d-%d: got %d bytes", start, end, n)
+	}
+	return err
+}
+
+// hashPartial reads back a partial file assembled out of order, computing
+// the hashes and secret scan a sequential download does as it goes
+func hashPartial(config *Config, fileName string) (*downloadInfo, error) {
+	part, _ := partialPaths(fileName)
+	file, err := os.Open(part)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	sha := sha256.New()
+	md := md5.New()
+	sinks := []io.Writer{sha, md}
+	var secrets *secretScanner
+	if config.secretRules != nil {
+		secrets = newSecretScanner(config.secretRules)
+		sinks = append(sinks, secrets)
+	}
+	size, err := io.Copy(io.MultiWriter(sinks...), file)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &downloadInfo{
+		path:   fileName,
+		size:   size,
+		sha256: hex.EncodeToString(sha.Sum(nil)),
+		md5:    hex.EncodeToString(md.Sum(nil)),
+	}
+	if secrets != nil {
+		info.secrets = secrets.hits
+	}
+	return info, nil
+}
+
+// discardPartial removes a partial download that can't be resumed
+func discardPartial(fileName string) {
+	part, statePath := partialPaths(fileName)
+	os.Remove(part)
+	os.Remove(statePath)
+}
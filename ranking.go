@@ -0,0 +1,94 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// Affixes most often seen on real exposed buckets
+var highValueAffixes = []string{"prod", "production", "backup", "backups"}
+
+// candidateScore is a heuristic for how likely a generated name is to exist;
+// lower is more likely. The bare keyword comes first, then prod/backup
+// variants, then the other common affixes, dates and numbers, and finally
+// exotic combinations and misspellings.
+func candidateScore(name string, bases, commonAffixes []string) int {
+	best := 5
+	for _, base := range bases {
+		if name == base {
+			return 0
+		}
+		if !strings.Contains(name, base) {
+			continue
+		}
+
+		rest := strings.Trim(strings.Replace(name, base, "", 1), "-._")
+		score := 4
+		switch {
+		case containsString(highValueAffixes, rest):
+			score = 1
+		case containsString(commonAffixes, rest):
+			score = 2
+		case rest != "" && strings.Trim(rest, "0123456789-q") == "":
+			score = 3
+		}
+		if score < best {
+			best = score
+		}
+	}
+	return best
+}
+
+// candidateBases are the forms of each keyword that generated names are built on
+func candidateBases(keywords []string) []string {
+	var bases []string
+	for _, keyword := range keywords {
+		word := strings.ToLower(strings.Join(strings.Fields(keyword), "-"))
+		bases = appendUnique(bases, word, extractBaseName(word), stripCorporateSuffixes(word))
+	}
+	return bases
+}
+
+// separatorRank orders names with the same score by how people usually
+// separate words in bucket names: hyphens, then none, then dots and underscores
+func separatorRank(name string) int {
+	switch {
+	case strings.Contains(name, "_"):
+		return 3
+	case strings.Contains(name, "."):
+		return 2
+	case strings.Contains(name, "-"):
+		return 0
+	default:
+		return 1
+	}
+}
+
+// rankCandidates sorts names from most to least likely to exist; within the
+// same score hyphenated and shorter names come first
+func rankCandidates(names, keywords []string) []string {
+	bases := candidateBases(keywords)
+	defaults := defaultPermutationRules()
+	commonAffixes := appendUnique(defaults.Prefixes, defaults.Suffixes...)
+
+	scores := make(map[string]int, len(names))
+	for _, name := range names {
+		scores[name] = candidateScore(name, bases, commonAffixes)
+	}
+
+	ranked := append([]string(nil), names...)
+	sort.Slice(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if scores[a] != scores[b] {
+			return scores[a] < scores[b]
+		}
+		if ra, rb := separatorRank(a), separatorRank(b); ra != rb {
+			return ra < rb
+		}
+		if len(a) != len(b) {
+			return len(a) < len(b)
+		}
+		return a < b
+	})
+	return ranked
+}
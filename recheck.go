@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// recheckBuckets lists the buckets with findings in an earlier run by
+// bucketKey, in the order they were found. Findings that don't name their
+// provider are taken to be on provider.
+func recheckBuckets(results *scanResults, provider string) []string {
+	seen := make(map[string]bool)
+	var buckets []string
+	for _, f := range results.Findings {
+		key := recheckKey(f, provider)
+		if f.Bucket != "" && !seen[key] {
+			seen[key] = true
+			buckets = append(buckets, key)
+		}
+	}
+	return buckets
+}
+
+func recheckKey(f Finding, provider string) string {
+	if f.Provider != "" {
+		provider = f.Provider
+	}
+	return bucketKey(provider, f.Bucket)
+}
+
+// routeRecheck returns the names to probe for buckets, routing each to the
+// provider it was found on
+func routeRecheck(routes *candidateRoutes, buckets []string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, key := range buckets {
+		provider, bucket := splitBucketKey(key)
+		routes.add(bucket, provider)
+		if !seen[bucket] {
+			seen[bucket] = true
+			names = append(names, bucket)
+		}
+	}
+	return names
+}
+
+// bucketStatus sums up each bucket, by bucketKey, by its most severe
+// finding, e.g. "bucket-listable (high)"
+func bucketStatus(findings []Finding, provider string) map[string]string {
+	worst := make(map[string]Finding)
+	for _, f := range findings {
+		key := recheckKey(f, provider)
+		if current, ok := worst[key]; !ok || severityRank(f.Severity) > severityRank(current.Severity) {
+			worst[key] = f
+		}
+	}
+
+	status := make(map[string]string, len(worst))
+	for bucket, f := range worst {
+		status[bucket] = fmt.Sprintf("%s (%s)", f.Type, f.Severity)
+	}
+	return status
+}
+
+// printRecheck reports how the status of each rechecked bucket has changed
+func printRecheck(buckets []string, provider string, before, after []Finding) {
+	old := bucketStatus(before, provider)
+	current := bucketStatus(after, provider)
+
+	// Buckets are named by provider too when the run covered several
+	providers := make(map[string]bool)
+	for _, key := range buckets {
+		p, _ := splitBucketKey(key)
+		providers[p] = true
+	}
+
+	var changed []string
+	for _, key := range buckets {
+		now, ok := current[key]
+		if !ok {
+			now = "not found"
+		}
+		if now != old[key] {
+			p, bucket := splitBucketKey(key)
+			if len(providers) > 1 {
+				bucket += " (" + p + ")"
+			}
+			changed = append(changed, fmt.Sprintf("\t%s: %s -> %s", bucket, old[key], now))
+		}
+	}
+
+	fmt.Printf("Rechecked %d bucket(s), %d changed:\n", len(buckets), len(changed))
+	if len(changed) > 0 {
+		fmt.Println(strings.Join(changed, "\n"))
+	}
+}
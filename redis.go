@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How long a Redis pop blocks waiting for a message
+const redisPopWait = 2 * time.Second
+
+// redisQueue keeps the --queue in Redis: a list of messages, one name per
+// line, plus sets of the names ever queued and the findings ever recorded.
+// A popped message is moved onto a processing list until it is acked, so the
+// names held by an instance that dies stay in Redis to be requeued.
+type redisQueue struct {
+	queue, processing, seen, findings string // keys
+
+	// Blocking pops get a connection of their own so pushes and claims
+	// aren't held up behind them
+	popConn, conn *redisConn
+}
+
+func newRedisQueue(config *Config, u *url.URL) (*redisQueue, error) {
+	prefix := u.Query().Get("key")
+	if prefix == "" {
+		prefix = "bucketfinder"
+	}
+	q := &redisQueue{
+		queue:      prefix + ":queue",
+		processing: prefix + ":processing",
+		seen:       prefix + ":seen",
+		findings:   prefix + ":findings",
+		popConn:    newRedisConn(config, u),
+		conn:       newRedisConn(config, u),
+	}
+	// Fail now rather than when the scan is under way
+	if _, err := q.conn.do("PING"); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+func (q *redisQueue) push(names []string) error {
+	// One SADD per name, pipelined, tells which names are new
+	cmds := make([][]string, len(names))
+	for i, name := range names {
+		cmds[i] = []string{"SADD", q.seen, name}
+	}
+	replies, err := q.conn.pipeline(cmds)
+	if err != nil {
+		return err
+	}
+	var fresh []string
+	for i, reply := range replies {
+		if n, ok := reply.(int64); ok && n == 1 {
+			fresh = append(fresh, names[i])
+		}
+	}
+	if len(fresh) == 0 {
+		return nil
+	}
+	_, err = q.conn.do("LPUSH", q.queue, strings.Join(fresh, "\n"))
+	return err
+}
+
+// pop moves the next message onto the processing list (BLMOVE needs Redis
+// 6.2), where it stays until ack
+func (q *redisQueue) pop(ctx context.Context) (*queueMessage, error) {
+	reply, err := q.popConn.do("BLMOVE", q.queue, q.processing, "RIGHT", "LEFT", strconv.Itoa(int(redisPopWait/time.Second)))
+	if err != nil || reply == nil {
+		return nil, err
+	}
+	body, ok := reply.(string)
+	if !ok {
+		return nil, fmt.Errorf("unexpected BLMOVE reply %v", reply)
+	}
+	return &queueMessage{names: strings.Split(body, "\n"), handle: body}, nil
+}
+
+// extend has nothing to do: a message stays on the processing list until
+// ack
+func (q *redisQueue) extend(msg *queueMessage) error {
+	return nil
+}
+
+// ack takes the message off the processing list
+func (q *redisQueue) ack(msg *queueMessage) error {
+	_, err := q.conn.do("LREM", q.processing, "1", msg.handle)
+	return err
+}
+
+func (q *redisQueue) claimFinding(key string) bool {
+	reply, err := q.conn.do("SADD", q.findings, key)
+	if err != nil {
+		// Better reported twice than not at all
+		return true
+	}
+	n, _ := reply.(int64)
+	return n == 1
+}
+
+// redisConn is one connection to Redis, redialled after an error, speaking
+// enough of RESP for the queue
+type redisConn struct {
+	mu     sync.Mutex
+	addr   string
+	user   string
+	pass   string
+	db     string
+	tls    *tls.Config
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// redisError is an error reply from the server
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+func newRedisConn(config *Config, u *url.URL) *redisConn {
+	c := &redisConn{addr: u.Host, db: strings.Trim(u.Path, "/")}
+	if u.Port() == "" {
+		c.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		c.user = u.User.Username()
+		c.pass, _ = u.User.Password()
+	}
+	if u.Scheme == "rediss" {
+		c.tls = &tls.Config{ServerName: u.Hostname()}
+		if config.tlsConfig != nil {
+			c.tls = config.tlsConfig.Clone()
+			c.tls.ServerName = u.Hostname()
+		}
+	}
+	return c
+}
+
+func (c *redisConn) do(args ...string) (interface{}, error) {
+	replies, err := c.pipeline([][]string{args})
+	if err != nil {
+		return nil, err
+	}
+	return replies[0], nil
+}
+
+// pipeline sends cmds in one go and reads their replies. An error reply
+// to any of them is returned as the error.
+func (c *redisConn) pipeline(cmds [][]string) ([]interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		if err := c.dial(); err != nil {
+			return nil, err
+		}
+	}
+	// Blocking pops must not time out before the server answers them
+	c.conn.SetDeadline(time.Now().Add(redisPopWait + 30*time.Second))
+
+	replies, err := c.roundTrip(cmds)
+	var replyErr redisError
+	if err != nil && !errors.As(err, &replyErr) {
+		c.conn.Close()
+		c.conn = nil
+	}
+	return replies, err
+}
+
+func (c *redisConn) dial() error {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	var conn net.Conn
+	var err error
+	if c.tls != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", c.addr, c.tls)
+	} else {
+		conn, err = dialer.Dial("tcp", c.addr)
+	}
+	if err != nil {
+		return err
+	}
+	c.conn, c.reader = conn, bufio.NewReader(conn)
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+
+	var setup [][]string
+	switch {
+	case c.user != "" && c.pass != "":
+		setup = append(setup, []string{"AUTH", c.user, c.pass})
+	case c.pass != "":
+		setup = append(setup, []string{"AUTH", c.pass})
+	case c.user != "":
+		setup = append(setup, []string{"AUTH", c.user})
+	}
+	if c.db != "" && c.db != "0" {
+		setup = append(setup, []string{"SELECT", c.db})
+	}
+	if len(setup) > 0 {
+		if _, err := c.roundTrip(setup); err != nil {
+			conn.Close()
+			c.conn = nil
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *redisConn) roundTrip(cmds [][]string) ([]interface{}, error) {
+	var buf strings.Builder
+	for _, args := range cmds {
+		fmt.Fprintf(&buf, "*%d\r\n", len(args))
+		for _, arg := range args {
+			fmt.Fprintf(&buf, "$%d\r\n%s\r\n", len(arg), arg)
+		}
+	}
+	if _, err := io.WriteString(c.conn, buf.String()); err != nil {
+		return nil, err
+	}
+
+	replies := make([]interface{}, len(cmds))
+	var firstErr error
+	for i := range cmds {
+		reply, err := readRESP(c.reader)
+		if replyErr, ok := err.(redisError); ok {
+			if firstErr == nil {
+				firstErr = replyErr
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		replies[i] = reply
+	}
+	return replies, firstErr
+}
+
+// readRESP reads one reply: a string, an int64, nil, or a slice of those
+func readRESP(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("empty RESP reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return string(data[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = readRESP(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("unexpected RESP reply %q", line)
+}
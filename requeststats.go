@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Limits that keep --request-stats small on long scans
+const (
+	maxStatsEndpoints = 50   // hosts tracked separately; the rest count as "other"
+	maxStatsSamples   = 4096 // latencies kept per endpoint for the percentiles
+)
+
+// requestStats counts the requests sent to each endpoint (host), their
+// status codes and failures, and samples their latencies
+type requestStats struct {
+	mu        sync.Mutex
+	endpoints map[string]*endpointStats
+}
+
+type endpointStats struct {
+	requests int
+	statuses map[int]int
+	errors   map[string]int
+	samples  []time.Duration // a uniform sample of at most maxStatsSamples latencies
+	total    time.Duration
+	max      time.Duration
+}
+
+func newRequestStats() *requestStats {
+	return &requestStats{endpoints: make(map[string]*endpointStats)}
+}
+
+func (s *requestStats) record(host string, latency time.Duration, status int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e := s.endpoints[host]
+	if e == nil {
+		if len(s.endpoints) >= maxStatsEndpoints {
+			host = "other"
+			e = s.endpoints[host]
+		}
+		if e == nil {
+			e = &endpointStats{statuses: make(map[int]int), errors: make(map[string]int)}
+			s.endpoints[host] = e
+		}
+	}
+
+	e.requests++
+	if err != nil {
+		e.errors[errorCategory(err)]++
+	} else {
+		e.statuses[status]++
+	}
+	// Reservoir sampling keeps every latency equally likely to be kept
+	if len(e.samples) < maxStatsSamples {
+		e.samples = append(e.samples, latency)
+	} else if i := rand.Intn(e.requests); i < maxStatsSamples {
+		e.samples[i] = latency
+	}
+	e.total += latency
+	if latency > e.max {
+		e.max = latency
+	}
+}
+
+// errorCategory names the kind of failure behind a request error
+func errorCategory(err error) string {
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	var certErr *tls.CertificateVerificationError
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var recordErr tls.RecordHeaderError
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.As(err, &dnsErr):
+		return "dns"
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "refused"
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE):
+		return "reset"
+	case errors.As(err, &certErr), errors.As(err, &unknownAuthority), errors.As(err, &hostnameErr),
+		errors.As(err, &recordErr), strings.Contains(err.Error(), "tls: "):
+		return "tls"
+	case strings.Contains(err.Error(), "proxy"), strings.Contains(err.Error(), "socks"):
+		return "proxy"
+	case strings.Contains(err.Error(), "EOF"):
+		return "eof"
+	}
+	return "other"
+}
+
+// percentile returns the p-th percentile (0-100) of sorted latencies
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(float64(len(sorted)-1) * p / 100)
+	return sorted[i]
+}
+
+// print shows each endpoint's counts and latency percentiles, busiest first
+func (s *requestStats) print() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.endpoints) == 0 {
+		return
+	}
+
+	hosts := make([]string, 0, len(s.endpoints))
+	for host := range s.endpoints {
+		hosts = append(hosts, host)
+	}
+	sort.Slice(hosts, func(i, j int) bool {
+		a, b := s.endpoints[hosts[i]], s.endpoints[hosts[j]]
+		if a.requests != b.requests {
+			return a.requests > b.requests
+		}
+		return hosts[i] < hosts[j]
+	})
+
+	fmt.Println("Requests by endpoint:")
+	for _, host := range hosts {
+		e := s.endpoints[host]
+		sorted := append([]time.Duration{}, e.samples...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		fmt.Printf("\t%s: %d request(s), p50 %s, p90 %s, p99 %s, max %s\n", host, e.requests,
+			roundLatency(percentile(sorted, 50)), roundLatency(percentile(sorted, 90)),
+			roundLatency(percentile(sorted, 99)), roundLatency(e.max))
+
+		codes := make([]int, 0, len(e.statuses))
+		for code := range e.statuses {
+			codes = append(codes, code)
+		}
+		sort.Ints(codes)
+		var parts []string
+		for _, code := range codes {
+			parts = append(parts, fmt.Sprintf("%d (%d)", code, e.statuses[code]))
+		}
+		if len(parts) > 0 {
+			fmt.Printf("\t\tstatus: %s\n", strings.Join(parts, ", "))
+		}
+
+		parts = parts[:0]
+		for _, category := range sortedKeys(e.errors) {
+			parts = append(parts, category+" ("+strconv.Itoa(e.errors[category])+")")
+		}
+		if len(parts) > 0 {
+			fmt.Printf("\t\terrors: %s\n", strings.Join(parts, ", "))
+		}
+	}
+}
+
+func roundLatency(d time.Duration) time.Duration {
+	if d >= time.Second {
+		return d.Round(10 * time.Millisecond)
+	}
+	return d.Round(time.Millisecond)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// statsTransport records every request in stats, retries included; the
+// latency is the time to the response headers
+type statsTransport struct {
+	base  http.RoundTripper
+	stats *requestStats
+}
+
+func (t *statsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	status := 0
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	t.stats.record(req.URL.Host, time.Since(start), status, err)
+	return resp, err
+}
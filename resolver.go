@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// How long lookups are cached. Go's resolver doesn't expose record TTLs, so
+// these are fixed: long enough to spare a slow resolver the endpoints every
+// request goes to, short enough to follow changes over a long scan.
+const (
+	dnsCacheTTL    = 5 * time.Minute
+	dnsNegativeTTL = time.Minute
+)
+
+// newResolver returns a resolver that queries servers in turn (a comma-
+// separated list, port 53 unless given), or the system resolver if none
+func newResolver(servers string) *net.Resolver {
+	var addrs []string
+	for _, server := range strings.Split(servers, ",") {
+		if server = strings.TrimSpace(server); server == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(strings.Trim(server, "[]"), "53")
+		}
+		addrs = append(addrs, server)
+	}
+	if len(addrs) == 0 {
+		return net.DefaultResolver
+	}
+
+	var next atomic.Uint64
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, addrs[next.Add(1)%uint64(len(addrs))])
+		},
+	}
+}
+
+// dnsCache caches lookups in-process, and makes concurrent lookups of the
+// same name wait for a single query
+type dnsCache struct {
+	resolver *net.Resolver
+	mu       sync.Mutex
+	entries  map[string]*dnsEntry
+}
+
+type dnsEntry struct {
+	done    chan struct{}
+	expires time.Time
+	addrs   []string
+	cname   string
+	err     error
+}
+
+func newDNSCache(resolver *net.Resolver) *dnsCache {
+	return &dnsCache{resolver: resolver, entries: make(map[string]*dnsEntry)}
+}
+
+// get returns the cached entry for key, running lookup to fill it if there
+// is none or it has expired. Lookups don't use the caller's context, since
+// others may be waiting on the result.
+func (c *dnsCache) get(ctx context.Context, key string, lookup func(ctx context.Context, e *dnsEntry)) (*dnsEntry, error) {
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	if !ok || (isClosed(e.done) && time.Now().After(e.expires)) {
+		e = &dnsEntry{done: make(chan struct{})}
+		c.entries[key] = e
+		c.mu.Unlock()
+
+		lookupCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		lookup(lookupCtx, e)
+		cancel()
+
+		var dnsErr *net.DNSError
+		switch {
+		case e.err == nil:
+			e.expires = time.Now().Add(dnsCacheTTL)
+		case errors.As(e.err, &dnsErr) && dnsErr.IsNotFound:
+			e.expires = time.Now().Add(dnsNegativeTTL)
+		default:
+			// Timeouts and server failures are not remembered
+			c.mu.Lock()
+			delete(c.entries, key)
+			c.mu.Unlock()
+		}
+		close(e.done)
+	} else {
+		c.mu.Unlock()
+	}
+
+	select {
+	case <-e.done:
+		return e, e.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func isClosed(ch chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+// LookupHost returns the addresses of host
+func (c *dnsCache) LookupHost(ctx context.Context, host string) ([]string, error) {
+	e, err := c.get(ctx, "host:"+strings.ToLower(host), func(ctx context.Context, e *dnsEntry) {
+		e.addrs, e.err = c.resolver.LookupHost(ctx, host)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return e.addrs, nil
+}
+
+// LookupCNAME returns the canonical name of host
+func (c *dnsCache) LookupCNAME(ctx context.Context, host string) (string, error) {
+	e, err := c.get(ctx, "cname:"+strings.ToLower(host), func(ctx context.Context, e *dnsEntry) {
+		e.cname, e.err = c.resolver.LookupCNAME(ctx, host)
+	})
+	if err != nil {
+		return "", err
+	}
+	return e.cname, nil
+}
+
+// dialContext dials addr's host at its cached addresses in turn, IPv6 ones
+// first if preferIPv6 is set
+func (c *dnsCache) dialContext(dialer *net.Dialer, preferIPv6 bool) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || net.ParseIP(host) != nil {
+			return dialer.DialContext(ctx, network, addr)
+		}
+		addrs, err := c.LookupHost(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		if len(addrs) == 0 {
+			return nil, &net.DNSError{Err: "no addresses", Name: host, IsNotFound: true}
+		}
+		if preferIPv6 {
+			var v6, v4 []string
+			for _, a := range addrs {
+				if strings.Contains(a, ":") {
+					v6 = append(v6, a)
+				} else {
+					v4 = append(v4, a)
+				}
+			}
+			addrs = append(v6, v4...)
+		}
+
+		for _, a := range addrs {
+			var conn net.Conn
+			if conn, err = dialer.DialContext(ctx, network, net.JoinHostPort(a, port)); err == nil {
+				return conn, nil
+			}
+		}
+		return nil, err
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// downloadState marks a partial download, kept in <file>.part.json beside
+// the <file>.part holding the bytes fetched so far
+type downloadState struct {
+	URL  string `json:"url"`
+	ETag string `json:"etag"`
+}
+
+func partialPaths(fileName string) (part, state string) {
+	return fileName + ".part", fileName + ".part.json"
+}
+
+// resumePoint returns how much of fileURL an earlier run already saved to
+// fileName and the ETag it had then, or 0 if there is nothing to resume.
+// Without an ETag the download cannot be resumed safely.
+func resumePoint(fileName, fileURL string) (int64, string) {
+	part, statePath := partialPaths(fileName)
+	data, err := os.ReadFile(statePath)
+	if err != nil {
+		return 0, ""
+	}
+	var state downloadState
+	if json.Unmarshal(data, &state) != nil || state.URL != fileURL || state.ETag == "" {
+		return 0, ""
+	}
+	info, err := os.Stat(part)
+	if err != nil {
+		return 0, ""
+	}
+	return info.Size(), state.ETag
+}
+
+// setRangeHeaders asks for the rest of an object from offset, unless it has
+// changed since it was partly downloaded
+func setRangeHeaders(req *http.Request, offset int64, etag string) {
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		req.Header.Set("If-Range", etag)
+	}
+}
+
+// resumedFrom is the offset a response continues the download from: the
+// start of a 206's Content-Range, or 0 for a full 200 response
+func resumedFrom(resp *http.Response) (int64, bool) {
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return 0, true
+	case http.StatusPartialContent:
+		// Content-Range: bytes 100-999/1000
+		var start int64
+		contentRange := strings.TrimPrefix(resp.Header.Get("Content-Range"), "bytes ")
+		if i := strings.IndexByte(contentRange, '-'); i > 0 {
+			if n, err := strconv.ParseInt(contentRange[:i], 10, 64); err == nil {
+				start = n
+			}
+		}
+		return start, start > 0
+	}
+	return 0, false
+}
+
+// openPartial opens fileName's partial file to continue writing at offset,
+// replaying what it holds so far into the writers (hashes and scanners),
+// and records the state needed to resume it later
+func openPartial(fileName, fileURL, etag string, offset int64, writers ...io.Writer) (*os.File, error) {
+	part, statePath := partialPaths(fileName)
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if offset > 0 {
+		existing, err := os.Open(part)
+		if err != nil {
+			return nil, err
+		}
+		_, err = io.CopyN(io.MultiWriter(writers...), existing, offset)
+		existing.Close()
+		if err != nil {
+			return nil, err
+		}
+		flags = os.O_WRONLY | os.O_APPEND
+	}
+
+	file, err := os.OpenFile(part, flags, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeJSONFile(statePath, downloadState{URL: fileURL, ETag: etag}); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// finishPartial moves a completed download into place
+func finishPartial(fileName string) error {
+	part, statePath := partialPaths(fileName)
+	if err := os.Rename(part, fileName); err != nil {
+		return err
+	}
+	os.Remove(statePath)
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Backoff before the first retry, doubling with each one up to the cap
+const (
+	retryBaseDelay = 500 * time.Millisecond
+	retryMaxDelay  = 10 * time.Second
+)
+
+// retryable reports whether a failed attempt is worth repeating: network
+// errors such as timeouts and connection resets, and 5xx responses. A 503
+// is S3 asking us to slow down, which adaptivePacing deals with instead. A
+// host name that doesn't exist won't on a retry either.
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		var dnsErr *net.DNSError
+		return !(errors.As(err, &dnsErr) && dnsErr.IsNotFound)
+	}
+	switch resp.StatusCode {
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryDelay is the backoff before retry n (counting from 1): a random
+// time up to the exponential delay, so workers that failed together don't
+// retry together
+func retryDelay(n int) time.Duration {
+	delay := retryBaseDelay << (n - 1)
+	if delay <= 0 || delay > retryMaxDelay {
+		delay = retryMaxDelay
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// doWithRetries sends req with client, retrying transient failures up to
+// config.retries times. A request with a body is only retried if the body
+// can be sent again, and none is retried once its context is done.
+func doWithRetries(config *Config, client *http.Client, req *http.Request) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := client.Do(req)
+		if attempt > config.retries || !retryable(resp, err) || req.Context().Err() != nil ||
+			(req.Body != nil && req.GetBody == nil) {
+			return resp, err
+		}
+
+		reason := ""
+		if err != nil {
+			reason = err.Error()
+		} else {
+			reason = resp.Status
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		}
+		delay := retryDelay(attempt)
+		if config.verbose {
+			fmt.Printf("Retrying %s %s in %v (%s)\n", req.Method, req.URL, delay.Round(time.Millisecond), reason)
+		}
+		if config.logger != nil {
+			config.logger.Printf("Retrying %s %s in %v (%s)", req.Method, req.URL, delay.Round(time.Millisecond), reason)
+		}
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(delay):
+		}
+		if req.GetBody != nil {
+			if req.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// candidateRoutes sends particular candidates to particular providers rather
+// than to every --provider: names taken from a provider's URLs (--extract,
+// --scrape) go to that provider only, and a throttled name goes back only to
+// the providers that throttled it
+type candidateRoutes struct {
+	mu        sync.Mutex
+	routes    map[string][]string // candidate -> providers
+	providers map[string]Provider // by comma-separated route
+	failed    map[string]error
+}
+
+// add routes name to provider as well as any it is routed to already
+func (r *candidateRoutes) add(name, provider string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.routes == nil {
+		r.routes = make(map[string][]string)
+	}
+	if !containsString(r.routes[name], provider) {
+		r.routes[name] = append(r.routes[name], provider)
+	}
+}
+
+// set routes name to providers alone
+func (r *candidateRoutes) set(name string, providers []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.routes == nil {
+		r.routes = make(map[string][]string)
+	}
+	r.routes[name] = providers
+}
+
+// provider returns what name is to be probed with: config.provider unless
+// it was routed elsewhere
+func (r *candidateRoutes) provider(config *Config, name string) (Provider, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	providers, ok := r.routes[name]
+	route := strings.Join(providers, ",")
+	if !ok || route == config.provider.Name() {
+		return config.provider, nil
+	}
+
+	// Each route's providers are built once
+	if p, ok := r.providers[route]; ok {
+		return p, nil
+	}
+	if err, ok := r.failed[route]; ok {
+		return nil, err
+	}
+	p, err := newProvider(route, config)
+	if err != nil {
+		if r.failed == nil {
+			r.failed = make(map[string]error)
+		}
+		r.failed[route] = fmt.Errorf("can't probe %s: %v", route, err)
+		return nil, r.failed[route]
+	}
+	if r.providers == nil {
+		r.providers = make(map[string]Provider)
+	}
+	r.providers[route] = p
+	return p, nil
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PermutationRules drive the core keyword permutations. Each prefix and
+// suffix is attached to the keyword with every joiner; patterns are
+// templates in which %kw% stands for the keyword (see expandPattern for the
+// other variables). Years and numbers are
+// ranges ("2019-2026", "0-20") appended to the keyword; months adds
+// acme-2024-q1, acme-202401 and acme-2024-01 style dates for each year;
+// typos adds misspelt and look-alike (0/o, 1/l) forms of each keyword;
+// org_words adds the orgWords dictionary to the prefixes and suffixes.
+//
+// The --rules file uses the same shape. By default its lists extend the
+// built-in ones; "mode": "replace" uses the file's lists alone.
+//
+//	{
+//	  "mode": "extend",
+//	  "prefixes": ["corp", "internal"],
+//	  "suffixes": ["assets", "uploads"],
+//	  "joiners": ["-", "."],
+//	  "patterns": ["%kw%-eu-backup"],
+//	  "years": "2018-2026",
+//	  "numbers": "0-20",
+//	  "months": true,
+//	  "typos": false,
+//	  "org_words": false
+//	}
+type PermutationRules struct {
+	Mode     string   `json:"mode"`
+	Prefixes []string `json:"prefixes"`
+	Suffixes []string `json:"suffixes"`
+	Joiners  []string `json:"joiners"`
+	Patterns []string `json:"patterns"`
+	Years    string   `json:"years"`
+	Numbers  string   `json:"numbers"`
+	Months   bool     `json:"months"`
+	Typos    bool     `json:"typos"`
+	OrgWords bool     `json:"org_words"`
+
+	// underscores keeps names with "_", which GCS allows but S3 doesn't
+	underscores bool
+}
+
+// Departments and teams that commonly get a bucket of their own
+var orgWords = []string{
+	"hr", "finance", "accounting", "payroll", "legal", "compliance", "audit", "marketing", "sales",
+	"support", "it", "security", "infosec", "engineering", "devops", "ops", "sre", "data",
+	"data-science", "analytics", "bi", "research", "ml", "design", "media", "product",
+}
+
+// defaultPermutationRules are the high-value combinations used when no
+// rules file is given; deliberately not a full cartesian product
+func defaultPermutationRules() *PermutationRules {
+	return &PermutationRules{
+		Prefixes: []string{"backup", "prod", "staging", "dev", "api", "web", "test", "s3"},
+		Suffixes: []string{"prod", "staging", "dev", "backup", "data", "api", "web", "test", "logs"},
+		// Underscores only survive for providers that allow them
+		Joiners: []string{"-", "", ".", "_"},
+		// A few combined patterns (very selective)
+		Patterns: []string{"backup-%kw%-prod", "prod-%kw%-backup", "%kw%-prod-backup", "%kw%-staging-backup"},
+		// Numbered variations (limited); years default to the last three
+		Numbers: "1-2",
+	}
+}
+
+// maxRangeSpan keeps a typo in a range from generating millions of names
+const maxRangeSpan = 1000
+
+// parseRange parses "N" or "N-M" into an inclusive range
+func parseRange(s string) (int, int, error) {
+	s = strings.TrimSpace(s)
+	lo, hi, isRange := strings.Cut(s, "-")
+	from, err := strconv.Atoi(strings.TrimSpace(lo))
+	if err != nil || from < 0 {
+		return 0, 0, fmt.Errorf("bad range %q (expected N or N-M)", s)
+	}
+	to := from
+	if isRange {
+		if to, err = strconv.Atoi(strings.TrimSpace(hi)); err != nil || to < from {
+			return 0, 0, fmt.Errorf("bad range %q (expected N or N-M)", s)
+		}
+	}
+	if to-from >= maxRangeSpan {
+		return 0, 0, fmt.Errorf("range %q is larger than %d", s, maxRangeSpan)
+	}
+	return from, to, nil
+}
+
+// yearRange returns the years to append to keywords
+func (r *PermutationRules) yearRange() (int, int) {
+	if r != nil && r.Years != "" {
+		if from, to, err := parseRange(r.Years); err == nil {
+			return from, to
+		}
+	}
+	currentYear := time.Now().Year()
+	return currentYear - 2, currentYear
+}
+
+// validate checks the ranges in r
+func (r *PermutationRules) validate() error {
+	for _, s := range []string{r.Years, r.Numbers} {
+		if s == "" {
+			continue
+		}
+		if _, _, err := parseRange(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadPermutationRules reads a --rules file and merges it with the defaults
+func loadPermutationRules(filename string) (*PermutationRules, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var file PermutationRules
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", filename, err)
+	}
+
+	for _, pattern := range file.Patterns {
+		if err := validatePattern(pattern); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := file.validate(); err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(file.Mode) {
+	case "replace":
+		if len(file.Joiners) == 0 {
+			file.Joiners = []string{"-"}
+		}
+		return &file, nil
+	case "", "extend":
+		rules := defaultPermutationRules()
+		rules.Prefixes = appendUnique(rules.Prefixes, file.Prefixes...)
+		rules.Suffixes = appendUnique(rules.Suffixes, file.Suffixes...)
+		rules.Joiners = appendUnique(rules.Joiners, file.Joiners...)
+		rules.Patterns = appendUnique(rules.Patterns, file.Patterns...)
+		if file.Years != "" {
+			rules.Years = file.Years
+		}
+		if file.Numbers != "" {
+			rules.Numbers = file.Numbers
+		}
+		rules.Months = file.Months
+		rules.Typos = file.Typos
+		rules.OrgWords = file.OrgWords
+		return rules, nil
+	default:
+		return nil, fmt.Errorf("unknown mode %q (use extend or replace)", file.Mode)
+	}
+}
+
+// addAffixLists adds the words from --prefix-list and --suffix-list files to rules
+func addAffixLists(rules *PermutationRules, prefixFile, suffixFile string) error {
+	for _, list := range []struct {
+		filename string
+		words    *[]string
+	}{
+		{prefixFile, &rules.Prefixes},
+		{suffixFile, &rules.Suffixes},
+	} {
+		if list.filename == "" {
+			continue
+		}
+		words, err := loadWordlist(list.filename)
+		if err != nil {
+			return err
+		}
+		for _, word := range words {
+			if strings.HasPrefix(word, "#") {
+				continue
+			}
+			*list.words = appendUnique(*list.words, strings.ToLower(word))
+		}
+	}
+	return nil
+}
+
+// addOrgWords uses the org-unit dictionary as both prefixes and suffixes
+func (r *PermutationRules) addOrgWords() {
+	r.Prefixes = appendUnique(r.Prefixes, orgWords...)
+	r.Suffixes = appendUnique(r.Suffixes, orgWords...)
+}
+
+// validName reports whether name is a usable bucket name under these rules
+func (r *PermutationRules) validName(name string) bool {
+	if r != nil && r.underscores {
+		name = strings.ReplaceAll(name, "_", "-")
+	}
+	return isValidBucketName(name)
+}
+
+// appendUnique appends the values not already in list
+func appendUnique(list []string, values ...string) []string {
+	for _, v := range values {
+		if !containsString(list, v) {
+			list = append(list, v)
+		}
+	}
+	return list
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Limits that keep --scrape from crawling a whole site
+const (
+	maxScrapeScripts    = 20
+	maxScrapeSubdomains = 50
+	maxScrapeBody       = 5 << 20
+)
+
+// bucketURLPattern matches a provider's storage URLs. The bucket name is in
+// the first group; for Azure the account and container are in two.
+type bucketURLPattern struct {
+	provider string
+	re       *regexp.Regexp
+}
+
+var (
+	// Storage URLs (S3, GCS, Spaces, Azure blobs)
+	bucketURLPatterns = []bucketURLPattern{
+		{"aws", regexp.MustCompile(`([a-z0-9][a-z0-9.-]{1,61}[a-z0-9])\.s3[.-](?:[a-z0-9-]+\.)?amazonaws\.com`)},
+		{"aws", regexp.MustCompile(`//s3[.-](?:[a-z0-9-]+\.)?amazonaws\.com/([a-z0-9][a-z0-9.-]{1,61}[a-z0-9])`)},
+		{"gcs", regexp.MustCompile(`([a-z0-9][a-z0-9._-]{1,61}[a-z0-9])\.storage\.googleapis\.com`)},
+		{"gcs", regexp.MustCompile(`//storage\.googleapis\.com/([a-z0-9][a-z0-9._-]{1,61}[a-z0-9])`)},
+		{"spaces", regexp.MustCompile(`([a-z0-9][a-z0-9-]{1,61}[a-z0-9])\.[a-z0-9]+\.digitaloceanspaces\.com`)},
+		{"azure", regexp.MustCompile(`([a-z0-9]{3,24})\.blob\.core\.windows\.net/([a-z0-9][a-z0-9-]{1,61}[a-z0-9])`)},
+	}
+
+	hostNamePattern  = regexp.MustCompile(`(?i)\b(?:[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,24}\b`)
+	scriptSrcPattern = regexp.MustCompile(`(?i)<script[^>]+src=["']([^"']+)["']`)
+	titlePattern     = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	siteNamePattern  = regexp.MustCompile(`(?i)<meta[^>]+(?:property|name)=["'](?:og:site_name|application-name)["'][^>]+content=["']([^"']+)["']`)
+	titleSeparators  = regexp.MustCompile(`\s+[|\-–—:·]\s+`)
+)
+
+// Page titles that say nothing about the product
+var genericTitles = []string{"home", "homepage", "welcome", "login", "log in", "sign in", "index"}
+
+// scrapeTarget fetches a page and the scripts it loads from the same site,
+// and pulls out the product name, subdomains and bucket URLs
+func scrapeTarget(config *Config, target string) (*seedResult, error) {
+	if !strings.Contains(target, "://") {
+		target = "https://" + target
+	}
+	page, err := url.Parse(target)
+	if err != nil || page.Host == "" {
+		return nil, fmt.Errorf("bad URL %q", target)
+	}
+
+	body, err := fetchScrapeBody(config.client, page.String())
+	if err != nil {
+		return nil, err
+	}
+
+	// The registered domain is a keyword itself and bounds what counts as the same site
+	result := &seedResult{}
+	domain := page.Hostname()
+	if net.ParseIP(domain) == nil {
+		if _, name, suffix := splitDomain(domain); suffix != "" {
+			domain = name + "." + suffix
+		}
+		result.keywords = append(result.keywords, domain)
+	}
+
+	// Product name: the site name if declared, otherwise the start of the title
+	name := ""
+	if m := siteNamePattern.FindStringSubmatch(body); m != nil {
+		name = productName(m[1])
+	} else if m := titlePattern.FindStringSubmatch(body); m != nil {
+		name = productName(m[1])
+	}
+	if name != "" {
+		result.keywords = appendUnique(result.keywords, name)
+	}
+
+	sources := []string{body}
+	scripts := 0
+	for _, m := range scriptSrcPattern.FindAllStringSubmatch(body, -1) {
+		if scripts >= maxScrapeScripts {
+			break
+		}
+		src, err := page.Parse(html.UnescapeString(m[1]))
+		if err != nil || !sameSite(src.Hostname(), domain) {
+			continue
+		}
+		scripts++
+		if js, err := fetchScrapeBody(config.client, src.String()); err == nil {
+			sources = append(sources, js)
+		} else if config.verbose {
+			fmt.Printf("Could not fetch %s: %v\n", src, err)
+		}
+	}
+
+	subdomains := 0
+	for _, source := range sources {
+		result.buckets = mergeNames(result.buckets, extractBucketNames(source, config.routes))
+		source = strings.ToLower(source)
+		for _, host := range hostNamePattern.FindAllString(source, -1) {
+			if subdomains < maxScrapeSubdomains && host != domain && sameSite(host, domain) && !containsString(result.keywords, host) {
+				result.keywords = append(result.keywords, host)
+				subdomains++
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// sameSite reports whether host is domain or one of its subdomains
+func sameSite(host, domain string) bool {
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+func fetchScrapeBody(client *http.Client, target string) (string, error) {
+	resp, err := client.Get(target)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s returned %s", target, resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxScrapeBody))
+	return string(body), err
+}
+
+// productName takes the first part of a title such as "Acme Cloud | Home",
+// or "" if that part is generic or too long to be a name
+func productName(title string) string {
+	title = strings.TrimSpace(html.UnescapeString(title))
+	for _, part := range titleSeparators.Split(title, -1) {
+		part = strings.TrimSpace(part)
+		if part == "" || containsString(genericTitles, strings.ToLower(part)) {
+			continue
+		}
+		if len(strings.Fields(part)) > 4 {
+			return ""
+		}
+		return part
+	}
+	return ""
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+)
+
+// secretRule is a pattern for a kind of secret. Where the pattern has a
+// group, the group is the secret itself, and it only counts if its Shannon
+// entropy (bits per character) is at least MinEntropy.
+type secretRule struct {
+	Name       string  `json:"name"`
+	Pattern    string  `json:"pattern"`
+	MinEntropy float64 `json:"min_entropy,omitempty"`
+
+	re *regexp.Regexp
+}
+
+var defaultSecretRules = []secretRule{
+	{Name: "AWS access key ID", Pattern: `\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`},
+	{Name: "AWS secret access key", Pattern: `(?i)aws.{0,20}?(?:secret|key).{0,20}?[=:\s'"]([A-Za-z0-9/+]{40})(?:[^A-Za-z0-9/+]|$)`, MinEntropy: 4},
+	{Name: "private key", Pattern: `-----BEGIN (?:RSA |EC |DSA |OPENSSH |PGP |ENCRYPTED )?PRIVATE KEY(?: BLOCK)?-----`},
+	{Name: "JSON Web Token", Pattern: `\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`},
+	{Name: "GitHub token", Pattern: `\bgh[pousr]_[A-Za-z0-9]{36}\b`},
+	{Name: "Slack token", Pattern: `\bxox[abprs]-[A-Za-z0-9-]{10,}`},
+	{Name: "password", Pattern: `(?i)(?:password|passwd|pwd|secret|api_?key|access_?token)["']?\s*[:=]\s*["']?([^\s"',;]{8,})`, MinEntropy: 3},
+}
+
+// loadSecretRules compiles the built-in rules plus those in filename, a JSON
+// list of rules, if given
+func loadSecretRules(filename string) ([]secretRule, error) {
+	rules := append([]secretRule{}, defaultSecretRules...)
+	if filename != "" {
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		var extra []secretRule
+		if err := json.Unmarshal(data, &extra); err != nil {
+			return nil, fmt.Errorf("parsing %s: %v", filename, err)
+		}
+		rules = append(rules, extra...)
+	}
+
+	for i := range rules {
+		re, err := regexp.Compile(rules[i].Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("secret rule %q: %v", rules[i].Name, err)
+		}
+		rules[i].re = re
+	}
+	return rules, nil
+}
+
+// Content scanned per file, and secrets reported per file
+const (
+	maxSecretScan = 64 << 20
+	maxSecretHits = 20
+	secretOverlap = 512
+)
+
+type secretHit struct {
+	rule  string
+	value string
+}
+
+// secretScanner is an io.Writer that looks for secrets in what is written
+// to it. The end of each write is kept and scanned again with the next one,
+// so secrets split between writes are still found.
+type secretScanner struct {
+	rules   []secretRule
+	tail    []byte
+	scanned int64
+	seen    map[secretHit]bool
+	hits    []secretHit
+}
+
+func newSecretScanner(rules []secretRule) *secretScanner {
+	return &secretScanner{rules: rules, seen: make(map[secretHit]bool)}
+}
+
+func (s *secretScanner) Write(p []byte) (int, error) {
+	if s.scanned >= maxSecretScan || len(s.hits) >= maxSecretHits {
+		return len(p), nil
+	}
+	s.scanned += int64(len(p))
+
+	data := append(s.tail, p...)
+	for _, rule := range s.rules {
+		for _, m := range rule.re.FindAllSubmatch(data, -1) {
+			value := m[0]
+			if len(m) > 1 && m[1] != nil {
+				value = m[1]
+			}
+			if rule.MinEntropy > 0 && shannonEntropy(value) < rule.MinEntropy {
+				continue
+			}
+			hit := secretHit{rule: rule.Name, value: string(value)}
+			if !s.seen[hit] && len(s.hits) < maxSecretHits {
+				s.seen[hit] = true
+				s.hits = append(s.hits, hit)
+			}
+		}
+	}
+
+	if len(data) > secretOverlap {
+		data = data[len(data)-secretOverlap:]
+	}
+	s.tail = append(s.tail[:0], data...)
+	return len(p), nil
+}
+
+// shannonEntropy is the entropy of b in bits per byte
+func shannonEntropy(b []byte) float64 {
+	if len(b) == 0 {
+		return 0
+	}
+	var counts [256]int
+	for _, c := range b {
+		counts[c]++
+	}
+	entropy := 0.0
+	for _, n := range counts {
+		if n > 0 {
+			p := float64(n) / float64(len(b))
+			entropy -= p * math.Log2(p)
+		}
+	}
+	return entropy
+}
+
+// redactSecret keeps enough of a secret to recognise it, and no more
+func redactSecret(value string) string {
+	if len(value) <= 8 {
+		return value[:len(value)/2] + "****"
+	}
+	return value[:4] + "****" + value[len(value)-4:]
+}
+
+// reportSecrets records the secrets found in a downloaded object
+func reportSecrets(ctx context.Context, config *Config, job downloadJob, info *downloadInfo) {
+	for _, hit := range info.secrets {
+		msg := fmt.Sprintf("<Secret> %s in %s: %s", hit.rule, job.fileURL, redactSecret(hit.value))
+		fmt.Println(msg)
+		if config.logger != nil {
+			config.logger.Println(msg)
+		}
+
+		recordFinding(ctx, config, Finding{
+			Bucket:   job.bucketName,
+			URL:      job.fileURL,
+			Type:     findingSecretExposed,
+			Key:      job.key,
+			Severity: "critical",
+			Message:  fmt.Sprintf("Public object %s in bucket %s contains a %s", job.key, job.bucketName, hit.rule),
+			Evidence: redactSecret(hit.value),
+			SHA256:   info.sha256,
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+// seedResult is what a seeding source (--scrape, --ct-domain, ...) learnt
+// about a target: keywords to permute, candidate names derived without
+// permuting, and bucket names referenced verbatim
+type seedResult struct {
+	keywords []string
+	names    []string
+	buckets  []string
+}
+
+func (s *seedResult) merge(other *seedResult) {
+	s.keywords = appendUnique(s.keywords, other.keywords...)
+	s.names = mergeNames(s.names, other.names)
+	s.buckets = mergeNames(s.buckets, other.buckets)
+}
+
+// mergeNames appends the names in extra not already in names, keeping order
+func mergeNames(names, extra []string) []string {
+	seen := make(map[string]bool, len(names)+len(extra))
+	merged := make([]string, 0, len(names)+len(extra))
+	for _, list := range [][]string{names, extra} {
+		for _, name := range list {
+			if !seen[name] {
+				seen[name] = true
+				merged = append(merged, name)
+			}
+		}
+	}
+	return merged
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+)
+
+// parseShard reads a --shard value such as "2/5": the second of five slices
+func parseShard(value string) (index, count int, err error) {
+	parts := strings.Split(value, "/")
+	if len(parts) == 2 {
+		index, err = strconv.Atoi(strings.TrimSpace(parts[0]))
+		if err == nil {
+			count, err = strconv.Atoi(strings.TrimSpace(parts[1]))
+		}
+	}
+	if len(parts) != 2 || err != nil || count < 1 || index < 1 || index > count {
+		return 0, 0, fmt.Errorf("bad shard %q, expected N/M such as 2/5", value)
+	}
+	return index, count, nil
+}
+
+// inShard reports whether name belongs to slice index of count. Names are
+// assigned by hash, so every machine agrees whatever order it reads them in.
+func inShard(name string, index, count int) bool {
+	h := fnv.New32a()
+	h.Write([]byte(strings.ToLower(name)))
+	return int(h.Sum32()%uint32(count)) == index-1
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// errSlowDown is returned by a probe the service refused to answer because
+// it is being sent too many requests
+var errSlowDown = errors.New("throttled by the service (SlowDown)")
+
+// Throttled candidates are probed again at the end of the scan, at most
+// this many more times
+const maxThrottledRounds = 3
+
+// Bounds of the adaptive delay each worker waits between candidates
+const (
+	minSlowDownDelay = 250 * time.Millisecond
+	maxSlowDownDelay = 10 * time.Second
+)
+
+// Successes in a row after which the delay is eased back towards the base
+const rampUpAfter = 20
+
+// isSlowDown reports whether a response is the service asking us to back
+// off: S3's 503 SlowDown, RequestLimitExceeded and similar error codes, or
+// a plain 429
+func isSlowDown(resp *http.Response, body []byte) bool {
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	var s3Error S3Error
+	if xml.Unmarshal(body, &s3Error) != nil {
+		return false
+	}
+	switch s3Error.Code {
+	case "SlowDown", "RequestLimitExceeded", "Throttling", "ThrottlingException", "TooManyRequests", "RequestThrottled":
+		return true
+	}
+	return false
+}
+
+// adaptivePacing replaces the fixed per-worker sleep: the delay doubles
+// each time the service asks us to slow down and eases back to the base
+// rate once requests go through again. Throttled candidates are kept to be
+// probed again later.
+type adaptivePacing struct {
+	mu       sync.Mutex
+	base     time.Duration
+	delay    time.Duration
+	streak   int
+	deferred []string
+
+	// A random extra delay in this range before each candidate (--jitter)
+	jitterMin, jitterMax time.Duration
+}
+
+func newAdaptivePacing(base time.Duration) *adaptivePacing {
+	return &adaptivePacing{base: base, delay: base}
+}
+
+// wait sleeps for the current delay before a worker's next candidate
+func (a *adaptivePacing) wait() {
+	delay := a.current()
+	if a.jitterMax > 0 {
+		delay += a.jitterMin + time.Duration(rand.Int63n(int64(a.jitterMax-a.jitterMin)+1))
+	}
+	time.Sleep(delay)
+}
+
+// current returns the delay between a worker's candidates right now
+func (a *adaptivePacing) current() time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.delay
+}
+
+// slowDown backs off after bucketName was throttled, and keeps it for
+// another round
+func (a *adaptivePacing) slowDown(config *Config, bucketName string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.deferred = append(a.deferred, bucketName)
+	a.streak = 0
+	if a.delay >= maxSlowDownDelay {
+		return
+	}
+	a.delay = min(max(2*a.delay, minSlowDownDelay), maxSlowDownDelay)
+
+	msg := fmt.Sprintf("Throttled by the service; slowing down to one request per %v per worker", a.delay)
+	fmt.Println(msg)
+	if config.logger != nil {
+		config.logger.Println(msg)
+	}
+}
+
+// ok records a request that went through, ramping back up after enough of
+// them in a row
+func (a *adaptivePacing) ok() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.delay <= a.base {
+		return
+	}
+	if a.streak++; a.streak >= rampUpAfter {
+		a.streak = 0
+		a.delay = max(a.delay*3/4, a.base)
+	}
+}
+
+// takeDeferred returns the candidates throttled so far and forgets them
+func (a *adaptivePacing) takeDeferred() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	names := a.deferred
+	a.deferred = nil
+	return names
+}
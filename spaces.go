@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DigitalOcean Spaces regions; each has its own S3-compatible endpoint
+var spacesRegions = []string{"nyc3", "sfo2", "sfo3", "ams3", "fra1", "lon1", "sgp1", "syd1", "blr1", "tor1", "atl1"}
+
+func newSpacesProvider(config *Config) (Provider, error) {
+	regions, err := selectRegions(config.spacesRegions, spacesRegions, "Spaces region")
+	if err != nil {
+		return nil, err
+	}
+
+	var hosts []string
+	for _, region := range regions {
+		hosts = append(hosts, fmt.Sprintf("https://%s.digitaloceanspaces.com", region))
+	}
+
+	return &s3Provider{
+		name:  "spaces",
+		hosts: func(string) []string { return hosts },
+	}, nil
+}
+
+// selectRegions validates a comma-separated region list against the known
+// ones, returning all of them when the list is empty
+func selectRegions(list string, known []string, kind string) ([]string, error) {
+	if list == "" {
+		return known, nil
+	}
+
+	var regions []string
+	for _, region := range strings.Split(list, ",") {
+		region = strings.ToLower(strings.TrimSpace(region))
+		if region == "" {
+			continue
+		}
+		if !containsString(known, region) {
+			return nil, fmt.Errorf("unknown %s %q (choose from %s)", kind, region, strings.Join(known, ", "))
+		}
+		regions = append(regions, region)
+	}
+	return regions, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	sqsPollWait   = 5 * time.Second // how long a receive waits for a message (long polling)
+	sqsVisibility = 2 * time.Minute // how long a received message is hidden from other instances
+)
+
+// sqsQueue keeps the --queue in Amazon SQS. A message taken off the queue is
+// kept hidden while it is probed and only goes once it is acked, so the names
+// held by an instance that dies come back within sqsVisibility. A FIFO queue
+// drops a batch queued again within its five-minute de-duplication window,
+// such as the same list queued from two machines; a standard queue
+// de-duplicates nothing. Findings can't be de-duplicated either, as SQS
+// keeps no shared state.
+type sqsQueue struct {
+	endpoint string // the queue URL
+	region   string
+	fifo     bool
+	creds    *awsCredentials
+	client   *http.Client
+}
+
+// newSQSQueue takes sqs://sqs.REGION.amazonaws.com/ACCOUNT/QUEUE, with
+// ?region= for endpoints whose host doesn't name it (sqs+http:// for a local
+// emulator) and ?profile= for the credentials to use
+func newSQSQueue(config *Config, u *url.URL) (*sqsQueue, error) {
+	query := u.Query()
+	region := query.Get("region")
+	if region == "" {
+		if parts := strings.Split(u.Hostname(), "."); len(parts) >= 4 && parts[0] == "sqs" {
+			region = parts[1]
+		} else {
+			return nil, fmt.Errorf("can't tell the region of %s; add ?region=", u.Host)
+		}
+	}
+	creds, err := loadAWSCredentials(query.Get("profile"))
+	if err != nil {
+		return nil, err
+	}
+
+	scheme := "https"
+	if u.Scheme == "sqs+http" {
+		scheme = "http"
+	}
+	endpoint := url.URL{Scheme: scheme, Host: u.Host, Path: u.Path}
+	fifo := strings.HasSuffix(u.Path, ".fifo")
+	if !fifo {
+		fmt.Println("Warning: a standard SQS queue doesn't de-duplicate; instances queueing the same names will each probe them (use a .fifo queue)")
+	}
+	return &sqsQueue{endpoint: endpoint.String(), region: region, fifo: fifo, creds: creds, client: config.client}, nil
+}
+
+func (q *sqsQueue) push(names []string) error {
+	body := strings.Join(names, "\n")
+	params := map[string]interface{}{
+		"QueueUrl":    q.endpoint,
+		"MessageBody": body,
+	}
+	// A batch is its own message group, so FIFO ordering doesn't keep
+	// instances from taking batches side by side
+	if q.fifo {
+		sum := sha256.Sum256([]byte(body))
+		id := hex.EncodeToString(sum[:])
+		params["MessageDeduplicationId"] = id
+		params["MessageGroupId"] = id
+	}
+	_, err := q.call(context.Background(), "SendMessage", params)
+	return err
+}
+
+func (q *sqsQueue) pop(ctx context.Context) (*queueMessage, error) {
+	body, err := q.call(ctx, "ReceiveMessage", map[string]interface{}{
+		"QueueUrl":            q.endpoint,
+		"MaxNumberOfMessages": 1,
+		"WaitTimeSeconds":     int(sqsPollWait / time.Second),
+		"VisibilityTimeout":   int(sqsVisibility / time.Second),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var received struct {
+		Messages []struct {
+			ReceiptHandle string
+			Body          string
+		}
+	}
+	if err := json.Unmarshal(body, &received); err != nil {
+		return nil, err
+	}
+	if len(received.Messages) == 0 {
+		return nil, nil
+	}
+	msg := received.Messages[0]
+	return &queueMessage{names: strings.Split(msg.Body, "\n"), handle: msg.ReceiptHandle}, nil
+}
+
+func (q *sqsQueue) ack(msg *queueMessage) error {
+	_, err := q.call(context.Background(), "DeleteMessage", map[string]interface{}{
+		"QueueUrl":      q.endpoint,
+		"ReceiptHandle": msg.handle,
+	})
+	return err
+}
+
+// extend hides the message from other instances for another sqsVisibility
+func (q *sqsQueue) extend(msg *queueMessage) error {
+	_, err := q.call(context.Background(), "ChangeMessageVisibility", map[string]interface{}{
+		"QueueUrl":          q.endpoint,
+		"ReceiptHandle":     msg.handle,
+		"VisibilityTimeout": int(sqsVisibility / time.Second),
+	})
+	return err
+}
+
+// claimFinding always says yes: SQS has nowhere to keep the findings, so a
+// batch probed again after its instance died reports its findings again
+func (q *sqsQueue) claimFinding(key string) bool {
+	return true
+}
+
+// call makes an SQS API call with the JSON protocol, returning the response
+// body
+func (q *sqsQueue) call(ctx context.Context, action string, params map[string]interface{}) ([]byte, error) {
+	payload, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", q.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.URL.Path = "/"
+	req.Header.Set("Content-Type", "application/x-amz-json-1.0")
+	req.Header.Set("X-Amz-Target", "AmazonSQS."+action)
+	signRequestPayload(req, q.creds, q.region, "sqs", sha256Hex(string(payload)), time.Now())
+
+	resp, err := q.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		json.Unmarshal(body, &failure)
+		if failure.Type != "" {
+			return nil, fmt.Errorf("%s %s: %s", action, failure.Type, failure.Message)
+		}
+		return nil, fmt.Errorf("%s returned %s", action, resp.Status)
+	}
+	return body, nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Largest objects kept per bucket for the contents summary
+const largestObjects = 5
+
+// bucketStats totals the objects of a listable bucket
+type bucketStats struct {
+	Objects   int          `json:"objects"`
+	TotalSize int64        `json:"total_size"`
+	Largest   []objectSize `json:"largest,omitempty"`
+	Partial   bool         `json:"partial,omitempty"`
+}
+
+type objectSize struct {
+	Key  string `json:"key"`
+	Size int64  `json:"size"`
+}
+
+func (s *bucketStats) add(key string, size int64) {
+	s.Objects++
+	s.TotalSize += size
+
+	if len(s.Largest) == largestObjects && size <= s.Largest[largestObjects-1].Size {
+		return
+	}
+	i := sort.Search(len(s.Largest), func(i int) bool { return s.Largest[i].Size < size })
+	s.Largest = append(s.Largest, objectSize{})
+	copy(s.Largest[i+1:], s.Largest[i:])
+	s.Largest[i] = objectSize{Key: key, Size: size}
+	if len(s.Largest) > largestObjects {
+		s.Largest = s.Largest[:largestObjects]
+	}
+}
+
+func (s *bucketStats) String() string {
+	count := fmt.Sprintf("%d objects", s.Objects)
+	if s.Objects == 1 {
+		count = "1 object"
+	}
+	if s.Partial {
+		count = "at least " + count
+	}
+	var largest []string
+	for _, obj := range s.Largest {
+		largest = append(largest, fmt.Sprintf("%s (%s)", obj.Key, formatSize(obj.Size)))
+	}
+	if len(largest) == 0 {
+		return fmt.Sprintf("%s, %s", count, formatSize(s.TotalSize))
+	}
+	return fmt.Sprintf("%s, %s; largest: %s", count, formatSize(s.TotalSize), strings.Join(largest, ", "))
+}
+
+// formatSize renders a byte count in binary units, e.g. 1.5 MiB
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// reportBucketStats prints and records the contents summary of a listed bucket
+func reportBucketStats(ctx context.Context, config *Config, bucketName, host string, stats *bucketStats, depth, workerId int) {
+	tabs := strings.Repeat("\t", depth+1)
+	workerPrefix := ""
+	if config.verbose {
+		workerPrefix = fmt.Sprintf("[Worker %d] ", workerId)
+	}
+
+	msg := fmt.Sprintf("%s%sContents of %s: %s", workerPrefix, tabs, bucketName, stats)
+	fmt.Println(msg)
+	if config.logger != nil {
+		config.logger.Println(msg)
+	}
+
+	recordFinding(ctx, config, Finding{
+		Bucket:   bucketName,
+		URL:      bucketURL(host, bucketName),
+		Type:     findingBucketContents,
+		Severity: "info",
+		Message:  fmt.Sprintf("Bucket %s holds %s", bucketName, stats),
+		Stats:    stats,
+	})
+}
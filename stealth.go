@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// Random delay added before each candidate with --stealth, unless --jitter
+// gives another range
+const defaultStealthJitter = "1s-5s"
+
+// Candidates --stealth shuffles at a time: a wordlist is streamed, so names
+// only trade places with those near them
+const shuffleWindow = 10000
+
+// Browser User-Agents that --stealth picks from for each request
+var stealthUserAgents = []string{
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/129.0.0.0 Safari/537.36",
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:131.0) Gecko/20100101 Firefox/131.0",
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/129.0.0.0 Safari/537.36 Edg/129.0.0.0",
+	"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/18.0 Safari/605.1.15",
+	"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/129.0.0.0 Safari/537.36",
+	"Mozilla/5.0 (Macintosh; Intel Mac OS X 14.7; rv:131.0) Gecko/20100101 Firefox/131.0",
+	"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/129.0.0.0 Safari/537.36",
+	"Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:131.0) Gecko/20100101 Firefox/131.0",
+	"Mozilla/5.0 (iPhone; CPU iPhone OS 18_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/18.0 Mobile/15E148 Safari/604.1",
+	"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/129.0.0.0 Mobile Safari/537.36",
+}
+
+// parseJitter parses a --jitter range such as 500ms-3s, or a single
+// duration meaning from nothing up to it
+func parseJitter(value string) (time.Duration, time.Duration, error) {
+	from, to, isRange := strings.Cut(value, "-")
+	if !isRange {
+		from, to = "0s", value
+	}
+	lo, err := time.ParseDuration(strings.TrimSpace(from))
+	if err != nil {
+		return 0, 0, err
+	}
+	hi, err := time.ParseDuration(strings.TrimSpace(to))
+	if err != nil {
+		return 0, 0, err
+	}
+	if lo < 0 || hi < lo {
+		return 0, 0, fmt.Errorf("expected min-max with 0 <= min <= max, got %q", value)
+	}
+	return lo, hi, nil
+}
+
+// shuffleBuffer passes names on to fn in random order, holding back up to
+// size of them to pick from
+type shuffleBuffer struct {
+	names []string
+	size  int
+	fn    func(name string)
+}
+
+func (b *shuffleBuffer) add(name string) {
+	b.names = append(b.names, name)
+	if len(b.names) >= b.size {
+		b.pop()
+	}
+}
+
+// pop passes on one of the held names at random
+func (b *shuffleBuffer) pop() {
+	i := rand.Intn(len(b.names))
+	name := b.names[i]
+	b.names[i] = b.names[len(b.names)-1]
+	b.names = b.names[:len(b.names)-1]
+	b.fn(name)
+}
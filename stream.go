@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"os"
+	"strings"
+)
+
+// Rate at which the Bloom filter mistakes a new name for a repeat
+const dedupFalsePositives = 0.001
+
+// bloomFilter remembers which names have been seen in a fixed amount of
+// memory, at the cost of now and then wrongly reporting a new one as seen
+type bloomFilter struct {
+	bits   []uint64
+	size   uint64
+	hashes int
+}
+
+// newBloomFilter sizes a filter for capacity names at dedupFalsePositives
+func newBloomFilter(capacity int) *bloomFilter {
+	if capacity < 1000 {
+		capacity = 1000
+	}
+	size := uint64(math.Ceil(-float64(capacity) * math.Log(dedupFalsePositives) / (math.Ln2 * math.Ln2)))
+	hashes := int(math.Round(float64(size) / float64(capacity) * math.Ln2))
+	return &bloomFilter{bits: make([]uint64, (size+63)/64), size: size, hashes: hashes}
+}
+
+// testAndAdd adds name, reporting whether it (probably) was already there
+func (b *bloomFilter) testAndAdd(name string) bool {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	sum := h.Sum64()
+	h1, h2 := sum&0xffffffff, sum>>32|1
+
+	seen := true
+	for i := 0; i < b.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % b.size
+		if b.bits[bit/64]&(1<<(bit%64)) == 0 {
+			seen = false
+			b.bits[bit/64] |= 1 << (bit % 64)
+		}
+	}
+	return seen
+}
+
+// dedupCapacity guesses how many names a wordlist holds from its size,
+// erring high: plain lists average well over 8 bytes a line, and gzip
+// rarely does better than 4:1 on them
+func dedupCapacity(filename string) int {
+	const streamed = 100000000
+	if filename == "-" {
+		return streamed
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		return streamed
+	}
+	if strings.HasSuffix(filename, ".gz") {
+		return int(info.Size() / 2)
+	}
+	return int(info.Size() / 8)
+}
+
+// candidateFeed hands out candidate names one at a time, so that a wordlist
+// is never held in memory: the names gathered up front (permutations,
+// extracted names, ...) come first, then the wordlist as it is read.
+// Repeats, out-of-scope names and known-public buckets are dealt with on
+// the way.
+type candidateFeed struct {
+	names      []string
+	wordlist   string
+	seen       *bloomFilter
+	exclusions []string
+
+	// Only names in this slice of the candidates are handed out (--shard)
+	shard, shards int
+
+	// Known-public buckets are passed to onKnown instead of being handed
+	// out, or handed out after everything else with probeKnown
+	known      map[string]string
+	probeKnown bool
+	onKnown    func(name, source string)
+
+	// Closing stop ends the feed early (an interrupted scan)
+	stop <-chan struct{}
+
+	// Hand the names out in random order, shuffling this many at a time
+	shuffle int
+
+	total, excluded, knownCount int
+}
+
+// stopped reports whether the feed has been told to stop
+func (f *candidateFeed) stopped() bool {
+	select {
+	case <-f.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// each calls fn for every candidate, in order, until the feed is stopped
+func (f *candidateFeed) each(fn func(name string)) error {
+	var buffer *shuffleBuffer
+	if f.shuffle > 0 {
+		buffer = &shuffleBuffer{size: f.shuffle, fn: fn}
+		fn = buffer.add
+	}
+	var deferred []string
+	emit := func(name string) {
+		if f.shards > 1 && !inShard(name, f.shard, f.shards) {
+			return
+		}
+		f.total++
+		if isExcluded(name, f.exclusions) {
+			f.excluded++
+			return
+		}
+		if source, ok := f.known[strings.ToLower(name)]; ok {
+			f.knownCount++
+			if f.probeKnown {
+				deferred = append(deferred, name)
+			} else if f.onKnown != nil {
+				f.onKnown(name, source)
+			}
+			return
+		}
+		fn(name)
+	}
+
+	for _, name := range f.names {
+		if f.stopped() {
+			return nil
+		}
+		if f.seen != nil {
+			f.seen.testAndAdd(name)
+		}
+		emit(name)
+	}
+	if f.wordlist != "" {
+		err := streamWordlist(f.wordlist, func(name string) bool {
+			if f.stopped() {
+				return false
+			}
+			if f.seen == nil || !f.seen.testAndAdd(name) {
+				emit(name)
+			}
+			return true
+		})
+		if err != nil {
+			return fmt.Errorf("reading wordlist %s: %v", f.wordlist, err)
+		}
+	}
+
+	for _, name := range deferred {
+		if f.stopped() {
+			break
+		}
+		fn(name)
+	}
+	for buffer != nil && len(buffer.names) > 0 && !f.stopped() {
+		buffer.pop()
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Labels too common to be a bucket name on their own; they are still used
+// next to the domain name
+var genericLabels = []string{"www", "mail", "smtp", "ftp", "api", "app", "cdn", "static",
+	"dev", "test", "qa", "stage", "staging", "prod", "vpn", "ns1", "ns2"}
+
+// seedFromSubdomains derives candidates from a file of subdomains, such as
+// the output of a subdomain enumeration tool. Each host is tried as-is and
+// with its labels joined to the domain name like --ct-domain does, and with
+// the apex stripped: each label and each chain of labels on their own
+// (api.payments.acme.com -> payments, api-payments, apipayments).
+func seedFromSubdomains(config *Config, filename string) (*seedResult, error) {
+	hosts, err := loadWordlist(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &seedResult{}
+	perms := make(map[string]bool)
+	count := 0
+	for _, host := range hosts {
+		host = strings.Trim(strings.ToLower(strings.TrimPrefix(host, "*.")), ".")
+		labels, name, suffix := splitDomain(host)
+		if suffix == "" {
+			continue
+		}
+		count++
+		result.keywords = appendUnique(result.keywords, name+"."+suffix)
+
+		addPermutation(perms, host)
+		generateDomainPermutations(perms, host)
+
+		for i := range labels {
+			if !containsString(genericLabels, labels[i]) {
+				addPermutation(perms, labels[i])
+			}
+			for j := i + 2; j <= len(labels); j++ {
+				addPermutation(perms, strings.Join(labels[i:j], "-"))
+				addPermutation(perms, strings.Join(labels[i:j], ""))
+			}
+		}
+	}
+
+	for name := range perms {
+		if config.rules.validName(name) {
+			result.names = append(result.names, name)
+		}
+	}
+	sort.Strings(result.names)
+
+	if config.verbose {
+		fmt.Printf("Read %d subdomains of %d domain(s) from %s\n", count, len(result.keywords), filename)
+	}
+	return result, nil
+}
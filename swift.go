@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Objects asked for per page of a container listing, Swift's usual maximum
+const swiftPageSize = 10000
+
+// swiftObject is one entry of a Swift container listing (?format=json)
+type swiftObject struct {
+	Name         string `json:"name"`
+	Bytes        int64  `json:"bytes"`
+	Hash         string `json:"hash"`
+	LastModified string `json:"last_modified"`
+	Subdir       string `json:"subdir"`
+}
+
+// swiftProvider probes OpenStack Swift containers at
+// <swift-url>/v1/AUTH_<tenant>/<container>. Containers with a public read
+// ACL (.r:*) serve objects anonymously, and with .rlistings also list them.
+// Swift can't tell an anonymous caller whether a private container exists,
+// so only public containers are reported.
+type swiftProvider struct {
+	baseURL  string
+	accounts []string
+}
+
+func newSwiftProvider(config *Config) (Provider, error) {
+	if config.swiftURL == "" {
+		return nil, fmt.Errorf("the swift provider needs --swift-url")
+	}
+	if config.swiftAccounts == "" {
+		return nil, fmt.Errorf("the swift provider needs --swift-account")
+	}
+
+	baseURL := strings.TrimRight(config.swiftURL, "/")
+	if !strings.HasSuffix(baseURL, "/v1") {
+		baseURL += "/v1"
+	}
+
+	var accounts []string
+	for _, account := range strings.Split(config.swiftAccounts, ",") {
+		account = strings.TrimSpace(account)
+		if account == "" {
+			continue
+		}
+		if !strings.HasPrefix(account, "AUTH_") {
+			account = "AUTH_" + account
+		}
+		accounts = append(accounts, account)
+	}
+
+	return &swiftProvider{baseURL: baseURL, accounts: accounts}, nil
+}
+
+func (p *swiftProvider) Name() string {
+	return "swift"
+}
+
+func (p *swiftProvider) Probe(ctx context.Context, config *Config, bucketName string, workerId int) error {
+	for _, account := range p.accounts {
+		host := fmt.Sprintf("%s/%s", p.baseURL, account)
+		containerURL := host + "/" + url.PathEscape(bucketName)
+		listURL := fmt.Sprintf("%s?format=json&limit=%d", containerURL, swiftPageSize)
+
+		resp, body, err := fetchURL(ctx, config, "GET", listURL)
+		if err != nil {
+			return err
+		}
+		if config.evidence != nil {
+			config.evidence.forget(listURL)
+		}
+
+		switch resp.StatusCode {
+		case http.StatusOK, http.StatusNoContent:
+			listResult, err := swiftListing(config, containerURL, bucketName, body)
+			if err != nil {
+				continue
+			}
+			processListing(ctx, config, listResult, string(body), bucketName, host, 0, workerId)
+			return nil
+
+		case http.StatusForbidden:
+			// Readable container (.r:*) without .rlistings
+			msg := fmt.Sprintf("Swift container found but listing denied: %s ( %s )", bucketName, containerURL)
+			fmt.Println(msg)
+			if config.logger != nil {
+				config.logger.Println(msg)
+			}
+			recordFinding(ctx, config, Finding{
+				Bucket:   bucketName,
+				URL:      containerURL,
+				Type:     findingBucketExists,
+				Severity: "low",
+				Message:  fmt.Sprintf("Swift container %s in %s may allow anonymous reads but not listing", bucketName, account),
+				Evidence: fmt.Sprintf("GET %s returned %s", listURL, resp.Status),
+			})
+			return nil
+
+		default:
+			if config.verbose {
+				fmt.Printf("[Worker %d] %s: %s\n", workerId, containerURL, resp.Status)
+			}
+		}
+	}
+	return nil
+}
+
+// swiftListing converts a page of a container listing into a bucket listing
+// that continues from the page's last name while pages come back full
+func swiftListing(config *Config, containerURL, bucketName string, body []byte) (ListBucketResult, error) {
+	var objects []swiftObject
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &objects); err != nil {
+			return ListBucketResult{}, err
+		}
+	}
+
+	listResult := ListBucketResult{Name: bucketName, IsTruncated: len(objects) >= swiftPageSize}
+	marker := ""
+	for _, obj := range objects {
+		if obj.Subdir != "" {
+			marker = obj.Subdir
+			continue
+		}
+		marker = obj.Name
+		listResult.Contents = append(listResult.Contents, ListBucketObject{
+			Key:          obj.Name,
+			LastModified: obj.LastModified,
+			ETag:         obj.Hash,
+			Size:         obj.Bytes,
+		})
+	}
+	if listResult.IsTruncated {
+		listResult.nextPage = func(ctx context.Context) (ListBucketResult, error) {
+			pageURL := fmt.Sprintf("%s?format=json&limit=%d&marker=%s", containerURL, swiftPageSize, url.QueryEscape(marker))
+			resp, body, err := fetchURL(ctx, config, "GET", pageURL)
+			if config.evidence != nil {
+				config.evidence.forget(pageURL)
+			}
+			if err != nil {
+				return ListBucketResult{}, err
+			}
+			if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+				return ListBucketResult{}, fmt.Errorf("listing page returned %s", resp.Status)
+			}
+			return swiftListing(config, containerURL, bucketName, body)
+		}
+	}
+	return listResult, nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+type Tagging struct {
+	XMLName xml.Name `xml:"Tagging"`
+	TagSet  []struct {
+		Key   string `xml:"Key"`
+		Value string `xml:"Value"`
+	} `xml:"TagSet>Tag"`
+}
+
+// checkTagging reads a found bucket's tags. Tags such as cost centres,
+// projects and owners are readable only through a misconfigured policy.
+func checkTagging(ctx context.Context, config *Config, bucketName, host string, depth, workerId int) {
+	tabs := strings.Repeat("\t", depth+1)
+	workerPrefix := ""
+	if config.verbose {
+		workerPrefix = fmt.Sprintf("[Worker %d] ", workerId)
+	}
+	taggingURL := bucketURL(host, bucketName) + "?tagging"
+
+	resp, body, err := fetchURL(ctx, config, "GET", taggingURL)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		if config.evidence != nil {
+			config.evidence.forget(taggingURL)
+		}
+		return
+	}
+	var tagging Tagging
+	if xml.Unmarshal(body, &tagging) != nil || len(tagging.TagSet) == 0 {
+		if config.evidence != nil {
+			config.evidence.forget(taggingURL)
+		}
+		return
+	}
+
+	tags := make(map[string]string)
+	var pairs []string
+	for _, tag := range tagging.TagSet {
+		tags[tag.Key] = tag.Value
+		pairs = append(pairs, tag.Key+"="+tag.Value)
+	}
+	sort.Strings(pairs)
+
+	msg := fmt.Sprintf("%s%sTags of %s are public: %s", workerPrefix, tabs, bucketName, strings.Join(pairs, ", "))
+	fmt.Println(msg)
+	if config.logger != nil {
+		config.logger.Println(msg)
+	}
+
+	recordFinding(ctx, config, Finding{
+		Bucket:   bucketName,
+		URL:      taggingURL,
+		Type:     findingBucketTagging,
+		Severity: "low",
+		Message:  fmt.Sprintf("Bucket %s exposes its tags: %s", bucketName, strings.Join(pairs, ", ")),
+		Evidence: evidenceSnippet(string(body)),
+		Tags:     tags,
+	})
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// TLS versions accepted by --tls-min-version
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// newTLSConfig builds the TLS settings for every connection from
+// --tls-min-version, --insecure, --ca-file and --client-cert, or returns
+// nil for Go's defaults
+func newTLSConfig(config *Config) (*tls.Config, error) {
+	if config.tlsMinVersion == "" && !config.insecure && config.caFile == "" && config.clientCert == "" && config.clientKey == "" {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{InsecureSkipVerify: config.insecure}
+
+	if config.tlsMinVersion != "" {
+		version, ok := tlsVersions[config.tlsMinVersion]
+		if !ok {
+			return nil, fmt.Errorf("unknown TLS version %q (use 1.0, 1.1, 1.2 or 1.3)", config.tlsMinVersion)
+		}
+		tlsConfig.MinVersion = version
+	}
+
+	// A lab CA or an intercepting proxy's CA is trusted on top of the system's
+	if config.caFile != "" {
+		pem, err := os.ReadFile(config.caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM certificates in %s", config.caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	// Endpoints that require mutual TLS get the client certificate
+	if config.clientCert != "" || config.clientKey != "" {
+		if config.clientCert == "" || config.clientKey == "" {
+			return nil, fmt.Errorf("--client-cert and --client-key go together")
+		}
+		cert, err := tls.LoadX509KeyPair(config.clientCert, config.clientKey)
+		if err != nil {
+			return nil, fmt.Errorf("client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// torProxy routes everything through Tor's SOCKS port. Host names are
+// passed to Tor to resolve, so DNS doesn't leak either.
+func torProxy(socksAddr string) (*url.URL, error) {
+	conn, err := net.DialTimeout("tcp", socksAddr, 5*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("Tor SOCKS port not reachable: %v", err)
+	}
+	conn.Close()
+	return &url.URL{Scheme: "socks5", Host: socksAddr}, nil
+}
+
+// torController asks Tor for new circuits through its control port
+type torController struct {
+	addr     string
+	password string
+}
+
+// newCircuit sends SIGNAL NEWNYM, after authenticating with the password if
+// one is given, else the cookie file Tor names, else without credentials
+func (c *torController) newCircuit() error {
+	conn, err := net.DialTimeout("tcp", c.addr, 5*time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	r := bufio.NewReader(conn)
+
+	auth := "AUTHENTICATE"
+	if c.password != "" {
+		auth += fmt.Sprintf(" %q", c.password)
+	} else {
+		lines, err := torCommand(conn, r, "PROTOCOLINFO 1")
+		if err != nil {
+			return err
+		}
+		if cookie := torCookie(lines); cookie != nil {
+			auth += " " + hex.EncodeToString(cookie)
+		}
+	}
+	if _, err := torCommand(conn, r, auth); err != nil {
+		return fmt.Errorf("authenticating: %v", err)
+	}
+	if _, err := torCommand(conn, r, "SIGNAL NEWNYM"); err != nil {
+		return err
+	}
+	torCommand(conn, r, "QUIT")
+	return nil
+}
+
+// torCommand sends a control port command and returns the lines of its
+// reply, or an error unless it ends in 250
+func torCommand(conn net.Conn, r *bufio.Reader, command string) ([]string, error) {
+	if _, err := fmt.Fprintf(conn, "%s\r\n", command); err != nil {
+		return nil, err
+	}
+	var lines []string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return lines, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		lines = append(lines, line)
+		// "250-..." continues the reply, "250 ..." ends it
+		if len(line) < 4 || line[3] == ' ' {
+			if !strings.HasPrefix(line, "250") {
+				return lines, fmt.Errorf("%s", line)
+			}
+			return lines, nil
+		}
+	}
+}
+
+// torCookie reads the authentication cookie named in a PROTOCOLINFO reply
+// if Tor accepts cookie authentication
+func torCookie(lines []string) []byte {
+	for _, line := range lines {
+		if !strings.Contains(line, "AUTH METHODS=") || !strings.Contains(line, "COOKIE") {
+			continue
+		}
+		_, file, ok := strings.Cut(line, `COOKIEFILE="`)
+		if !ok {
+			return nil
+		}
+		file, _, _ = strings.Cut(file, `"`)
+		cookie, err := os.ReadFile(strings.ReplaceAll(file, `\\`, `\`))
+		if err != nil {
+			return nil
+		}
+		return cookie
+	}
+	return nil
+}
+
+// torRotator asks for a new circuit every so many requests. Connections
+// already open stay on their circuit, so idle ones are closed to make the
+// next requests open new connections over the new one.
+type torRotator struct {
+	base  *http.Transport
+	ctl   *torController
+	every int
+
+	mu    sync.Mutex
+	count int
+}
+
+func (t *torRotator) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	t.count++
+	rotate := t.count%t.every == 0
+	t.mu.Unlock()
+
+	if rotate {
+		if err := t.ctl.newCircuit(); err != nil {
+			fmt.Printf("Could not get a new Tor circuit: %v\n", err)
+		} else {
+			t.base.CloseIdleConnections()
+		}
+	}
+	return t.base.RoundTrip(req)
+}
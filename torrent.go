@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// checkTorrent tries the legacy ?torrent sub-resource of an object that
+// cannot be read directly. S3 served a .torrent for any object readable
+// through it, and some policies deny s3:GetObject without covering it.
+func checkTorrent(ctx context.Context, config *Config, fileURL, bucketName, key string) bool {
+	torrentURL := fileURL + "?torrent"
+	resp, body, err := fetchURL(ctx, config, "GET", torrentURL)
+	// A torrent file is a bencoded dictionary
+	if err != nil || resp.StatusCode != http.StatusOK || len(body) == 0 || body[0] != 'd' {
+		if config.evidence != nil {
+			config.evidence.forget(torrentURL)
+		}
+		return false
+	}
+
+	recordFinding(ctx, config, Finding{
+		Bucket:   bucketName,
+		URL:      torrentURL,
+		Type:     findingObjectTorrent,
+		Key:      key,
+		Severity: "medium",
+		Message:  fmt.Sprintf("Object %s in bucket %s is denied directly but served as a torrent", key, bucketName),
+		Evidence: fmt.Sprintf("GET %s returned 200 OK with a %d byte torrent", torrentURL, len(body)),
+	})
+	return true
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// newTransport builds the one Transport every request goes through, so
+// connections to the storage endpoints are kept alive and shared between
+// workers. Go's default keeps just two idle connections per host, far fewer
+// than the workers probing the same endpoint at once, so most requests would
+// pay for a new TCP and TLS handshake.
+func newTransport(config *Config) *http.Transport {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	// Names are resolved through the in-process cache, and with
+	// --dualstack connect over IPv6 when the host has an IPv6 address
+	transport.DialContext = config.dns.dialContext(dialer, config.dualstack)
+
+	// $HTTPS_PROXY, $HTTP_PROXY and $NO_PROXY are honoured unless --proxy
+	// names one. Credentials in the URL are sent as Proxy-Authorization,
+	// and HTTPS goes through a CONNECT tunnel.
+	if config.proxy != nil {
+		transport.Proxy = http.ProxyURL(config.proxy)
+	}
+	if config.tlsConfig != nil {
+		transport.TLSClientConfig = config.tlsConfig
+	}
+
+	// Enough idle connections for every probe and download in flight,
+	// unless --max-idle-per-host says otherwise
+	perHost := config.workers + config.downloadWorkers*max(config.splitParts, 1)
+	perHost = max(perHost, 16)
+	if config.maxIdlePerHost > 0 {
+		perHost = config.maxIdlePerHost
+	}
+	transport.MaxIdleConnsPerHost = perHost
+	transport.MaxIdleConns = max(4*perHost, 100)
+	transport.IdleConnTimeout = config.idleTimeout
+
+	// A new connection for every request, e.g. to spread them over a
+	// front-end's addresses
+	transport.DisableKeepAlives = config.disableKeepAlive
+	return transport
+}
+
+// parseProxyURL checks a --proxy URL; a bare host:port is taken as an HTTP
+// proxy
+func parseProxyURL(proxy string) (*url.URL, error) {
+	if !strings.Contains(proxy, "://") {
+		proxy = "http://" + proxy
+	}
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q (use http, https or socks5)", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("no host in %q", proxy)
+	}
+	return u, nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type ListMultipartUploadsResult struct {
+	XMLName     xml.Name `xml:"ListMultipartUploadsResult"`
+	Bucket      string   `xml:"Bucket"`
+	IsTruncated bool     `xml:"IsTruncated"`
+	Uploads     []struct {
+		Key       string `xml:"Key"`
+		UploadId  string `xml:"UploadId"`
+		Initiated string `xml:"Initiated"`
+		Initiator string `xml:"Initiator>DisplayName"`
+	} `xml:"Upload"`
+}
+
+// checkUploads lists a found bucket's in-progress multipart uploads. Their
+// keys are files that never show up in the object listing, and anyone
+// holding an upload ID may be able to add parts to or complete the upload.
+func checkUploads(ctx context.Context, config *Config, bucketName, host string, depth, workerId int) {
+	tabs := strings.Repeat("\t", depth+1)
+	workerPrefix := ""
+	if config.verbose {
+		workerPrefix = fmt.Sprintf("[Worker %d] ", workerId)
+	}
+	uploadsURL := bucketURL(host, bucketName) + "?uploads"
+
+	resp, body, err := fetchURL(ctx, config, "GET", uploadsURL)
+	var uploads ListMultipartUploadsResult
+	if err != nil || resp.StatusCode != http.StatusOK || xml.Unmarshal(body, &uploads) != nil {
+		if config.evidence != nil {
+			config.evidence.forget(uploadsURL)
+		}
+		if err == nil && config.verbose {
+			fmt.Printf("%s%sMultipart uploads of %s not listable (%s)\n", workerPrefix, tabs, bucketName, resp.Status)
+		}
+		return
+	}
+
+	msg := fmt.Sprintf("%s%sMultipart uploads of %s are listable (%d in progress)", workerPrefix, tabs, bucketName, len(uploads.Uploads))
+	if uploads.IsTruncated {
+		msg = fmt.Sprintf("%s%sMultipart uploads of %s are listable (more than %d in progress)", workerPrefix, tabs, bucketName, len(uploads.Uploads))
+	}
+	var keys []string
+	for _, upload := range uploads.Uploads {
+		msg += fmt.Sprintf("\n%s%s\t<Upload> %s (started %s, upload ID %s)", workerPrefix, tabs, upload.Key, upload.Initiated, upload.UploadId)
+		keys = append(keys, upload.Key)
+	}
+	fmt.Println(msg)
+	if config.logger != nil {
+		config.logger.Println(msg)
+	}
+
+	// Listable but empty is still a misconfiguration, just a quieter one
+	severity := "low"
+	message := fmt.Sprintf("Bucket %s lets anyone list its multipart uploads (none in progress)", bucketName)
+	if len(keys) > 0 {
+		severity = "medium"
+		message = fmt.Sprintf("Bucket %s lets anyone list its %d in-progress multipart uploads: %s", bucketName, len(keys), strings.Join(keys, ", "))
+	}
+	recordFinding(ctx, config, Finding{
+		Bucket:   bucketName,
+		URL:      uploadsURL,
+		Type:     findingUploadsListable,
+		Severity: severity,
+		Message:  message,
+		Evidence: evidenceSnippet(string(body)),
+	})
+}
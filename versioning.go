@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+type VersioningConfiguration struct {
+	XMLName   xml.Name `xml:"VersioningConfiguration"`
+	Status    string   `xml:"Status"`
+	MfaDelete string   `xml:"MfaDelete"`
+}
+
+type ListVersionsResult struct {
+	XMLName             xml.Name        `xml:"ListVersionsResult"`
+	Name                string          `xml:"Name"`
+	IsTruncated         bool            `xml:"IsTruncated"`
+	NextKeyMarker       string          `xml:"NextKeyMarker"`
+	NextVersionIdMarker string          `xml:"NextVersionIdMarker"`
+	Versions            []ObjectVersion `xml:"Version"`
+	DeleteMarkers       []ObjectVersion `xml:"DeleteMarker"`
+}
+
+type ObjectVersion struct {
+	Key          string `xml:"Key"`
+	VersionId    string `xml:"VersionId"`
+	IsLatest     bool   `xml:"IsLatest"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size"`
+}
+
+// checkVersioning reads a found bucket's versioning status and lists the
+// object versions no longer in its current listing: overwritten ones and
+// those of deleted objects. Readable old versions are reported.
+func checkVersioning(ctx context.Context, config *Config, bucketName, host string, depth, workerId int) {
+	tabs := strings.Repeat("\t", depth+1)
+	workerPrefix := ""
+	if config.verbose {
+		workerPrefix = fmt.Sprintf("[Worker %d] ", workerId)
+	}
+	base := bucketURL(host, bucketName)
+
+	_, body, err := fetchURL(ctx, config, "GET", base+"?versioning")
+	if err != nil {
+		return
+	}
+	var versioning VersioningConfiguration
+	if xml.Unmarshal(body, &versioning) == nil && versioning.Status != "" {
+		msg := fmt.Sprintf("%s%sVersioning %s on %s", workerPrefix, tabs, strings.ToLower(versioning.Status), bucketName)
+		fmt.Println(msg)
+		if config.logger != nil {
+			config.logger.Println(msg)
+		}
+		recordFinding(ctx, config, Finding{
+			Bucket:   bucketName,
+			URL:      base + "?versioning",
+			Type:     findingVersioning,
+			Severity: "info",
+			Message:  fmt.Sprintf("Bucket %s has versioning %s and its configuration is publicly readable", bucketName, strings.ToLower(versioning.Status)),
+			Evidence: evidenceSnippet(string(body)),
+		})
+	}
+	if config.evidence != nil {
+		config.evidence.forget(base + "?versioning")
+	}
+
+	// Old versions can be listed even where versioning has since been suspended
+	query := url.Values{}
+	listed := 0
+	for {
+		versionsURL := base + "?versions"
+		if len(query) > 0 {
+			versionsURL += "&" + query.Encode()
+		}
+		_, body, err := fetchURL(ctx, config, "GET", versionsURL)
+		if config.evidence != nil {
+			config.evidence.forget(versionsURL)
+		}
+		if err != nil {
+			return
+		}
+		var result ListVersionsResult
+		if xml.Unmarshal(body, &result) != nil || result.Name == "" {
+			return
+		}
+
+		deleted := make(map[string]bool)
+		for _, marker := range result.DeleteMarkers {
+			if marker.IsLatest {
+				deleted[marker.Key] = true
+			}
+		}
+		for _, version := range result.Versions {
+			if ctx.Err() != nil {
+				return
+			}
+			if version.IsLatest || strings.HasSuffix(version.Key, "/") || !config.keys.match(version.Key) {
+				continue
+			}
+			if config.maxKeys > 0 && listed >= config.maxKeys {
+				return
+			}
+			listed++
+			checkObjectVersion(ctx, config, bucketName, host, version, deleted[version.Key], depth, workerId)
+		}
+
+		if !result.IsTruncated || result.NextKeyMarker == "" {
+			return
+		}
+		query.Set("key-marker", result.NextKeyMarker)
+		query.Set("version-id-marker", result.NextVersionIdMarker)
+	}
+}
+
+// checkObjectVersion reports whether an old version of an object can be read
+func checkObjectVersion(ctx context.Context, config *Config, bucketName, host string, version ObjectVersion, deleted bool, depth, workerId int) {
+	tabs := strings.Repeat("\t", depth+1)
+	workerPrefix := ""
+	if config.verbose {
+		workerPrefix = fmt.Sprintf("[Worker %d] ", workerId)
+	}
+
+	versionURL := objectURL(host, bucketName, version.Key) + "?versionId=" + url.QueryEscape(version.VersionId)
+	readable := checkFileReadable(ctx, config, versionURL)
+	if config.evidence != nil && !readable {
+		config.evidence.forget(versionURL)
+	}
+	if !readable && ctx.Err() != nil {
+		return
+	}
+
+	state := "Old version"
+	if deleted {
+		state = "Deleted"
+	}
+	access := "Private"
+	if readable {
+		access = "Public"
+	}
+	msg := fmt.Sprintf("%s%s<%s %s> %s", workerPrefix, tabs, state, access, versionURL)
+	fmt.Println(msg)
+	if config.logger != nil {
+		config.logger.Println(msg)
+	}
+
+	if readable {
+		recordFinding(ctx, config, Finding{
+			Bucket:   bucketName,
+			URL:      versionURL,
+			Type:     findingObjectVersion,
+			Key:      version.Key,
+			Severity: "medium",
+			Message:  fmt.Sprintf("%s version %s of %s in bucket %s is publicly readable", state, version.VersionId, version.Key, bucketName),
+			Evidence: fmt.Sprintf("HEAD %s returned 200 OK", versionURL),
+		})
+	}
+}
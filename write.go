@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Time allowed for removing a test upload, however much of the bucket's
+// budget is left
+const cleanupTimeout = 15 * time.Second
+
+// cleanupContext is for undoing a test upload: it outlives the per-bucket
+// budget and an interrupted scan, so nothing is left in the target
+func cleanupContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), cleanupTimeout)
+}
+
+// canaryKey is a unique name for a test upload, recognisable by the bucket owner
+func canaryKey() string {
+	token := make([]byte, 8)
+	rand.Read(token)
+	return "bucket-finder-canary-" + hex.EncodeToString(token) + ".txt"
+}
+
+// checkWrite tries to upload a small, uniquely named canary object to the
+// bucket and deletes it again straight away. Only run with --check-write
+// and --confirm-write, as it changes the target.
+func checkWrite(ctx context.Context, config *Config, bucketName, host string, depth, workerId int) {
+	tabs := strings.Repeat("\t", depth+1)
+	workerPrefix := ""
+	if config.verbose {
+		workerPrefix = fmt.Sprintf("[Worker %d] ", workerId)
+	}
+
+	key := canaryKey()
+	canaryURL := objectURL(host, bucketName, key)
+	content := fmt.Sprintf("bucket_finder write test, %s\n", time.Now().UTC().Format(time.RFC3339))
+
+	req, err := http.NewRequestWithContext(ctx, "PUT", canaryURL, strings.NewReader(content))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "text/plain")
+	resp, body, err := doRequest(config, req)
+	if err != nil {
+		return
+	}
+	// S3-compatible stores may answer 201 or 204 rather than 200
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		if config.verbose {
+			fmt.Printf("%s%sAnonymous write to %s refused (%s)\n", workerPrefix, tabs, bucketName, resp.Status)
+		}
+		return
+	}
+
+	// Clean up before reporting, which may be slow
+	cleanup, cancel := cleanupContext(ctx)
+	deleteResp, _, deleteErr := fetchURL(cleanup, config, "DELETE", canaryURL)
+	cancel()
+	// The DELETE was recorded under the same URL; the PUT is the evidence
+	if config.evidence != nil {
+		config.evidence.recordExchange(canaryURL, resp, body)
+	}
+
+	msg := fmt.Sprintf("%s%s<Writable> %s", workerPrefix, tabs, canaryURL)
+	fmt.Println(msg)
+	if config.logger != nil {
+		config.logger.Println(msg)
+	}
+	recordFinding(ctx, config, Finding{
+		Bucket:   bucketName,
+		URL:      canaryURL,
+		Type:     findingBucketWritable,
+		Key:      key,
+		Severity: "high",
+		Message:  fmt.Sprintf("Bucket %s accepts anonymous uploads", bucketName),
+		Evidence: fmt.Sprintf("PUT %s returned %s", canaryURL, resp.Status),
+	})
+
+	// A canary that can't be deleted has to be reported to the owner
+	if deleteErr != nil || deleteResp.StatusCode >= 300 {
+		status := "no response"
+		if deleteErr == nil {
+			status = deleteResp.Status
+		}
+		msg := fmt.Sprintf("%s%sCould not delete canary %s (%s); it is still in the bucket", workerPrefix, tabs, canaryURL, status)
+		fmt.Println(msg)
+		if config.logger != nil {
+			config.logger.Println(msg)
+		}
+	}
+}
+
+type InitiateMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+	Key      string   `xml:"Key"`
+	UploadId string   `xml:"UploadId"`
+}
+
+// checkMultipart starts a multipart upload and aborts it at once. Being
+// allowed to start one shows s3:PutObject is granted, without any object
+// being written.
+func checkMultipart(ctx context.Context, config *Config, bucketName, host string, depth, workerId int) {
+	tabs := strings.Repeat("\t", depth+1)
+	workerPrefix := ""
+	if config.verbose {
+		workerPrefix = fmt.Sprintf("[Worker %d] ", workerId)
+	}
+
+	key := canaryKey()
+	uploadURL := objectURL(host, bucketName, key)
+
+	resp, body, err := fetchURL(ctx, config, "POST", uploadURL+"?uploads")
+	if err != nil {
+		return
+	}
+	var upload InitiateMultipartUploadResult
+	if resp.StatusCode != http.StatusOK || xml.Unmarshal(body, &upload) != nil || upload.UploadId == "" {
+		if config.evidence != nil {
+			config.evidence.forget(uploadURL + "?uploads")
+		}
+		if config.verbose {
+			fmt.Printf("%s%sAnonymous multipart upload to %s refused (%s)\n", workerPrefix, tabs, bucketName, resp.Status)
+		}
+		return
+	}
+
+	// Abort before reporting, which may be slow
+	abortURL := uploadURL + "?uploadId=" + url.QueryEscape(upload.UploadId)
+	cleanup, cancel := cleanupContext(ctx)
+	abortResp, _, abortErr := fetchURL(cleanup, config, "DELETE", abortURL)
+	cancel()
+	if config.evidence != nil {
+		config.evidence.forget(abortURL)
+	}
+
+	msg := fmt.Sprintf("%s%s<Writable (multipart)> %s", workerPrefix, tabs, bucketURL(host, bucketName))
+	fmt.Println(msg)
+	if config.logger != nil {
+		config.logger.Println(msg)
+	}
+	recordFinding(ctx, config, Finding{
+		Bucket:   bucketName,
+		URL:      uploadURL + "?uploads",
+		Type:     findingBucketWritable,
+		Severity: "high",
+		Message:  fmt.Sprintf("Bucket %s lets anyone start multipart uploads (s3:PutObject)", bucketName),
+		Evidence: evidenceSnippet(string(body)),
+	})
+
+	if abortErr != nil || abortResp.StatusCode >= 300 {
+		msg := fmt.Sprintf("%s%sCould not abort multipart upload %s for %s", workerPrefix, tabs, upload.UploadId, bucketName)
+		fmt.Println(msg)
+		if config.logger != nil {
+			config.logger.Println(msg)
+		}
+	}
+}